@@ -6,8 +6,16 @@ type Aggregate struct {
 	arg   string // 参数
 	alias string // 别名
 	table TableReference
+	// argExpr 不为空的时候代表对表达式聚合，此时忽略 arg 和 table
+	argExpr Expression
+	// distinct 对应 COUNT(DISTINCT col) 这种用法
+	distinct bool
+	// separator GROUP_CONCAT 的分隔符
+	separator string
 }
 
+const aggGroupConcat = "GROUP_CONCAT"
+
 func (a Aggregate) fieldName() string {
 	return a.arg
 }
@@ -23,12 +31,16 @@ func (a Aggregate) selectedAlias() string {
 func (Aggregate) expr() {}
 
 func (a Aggregate) As(alias string) Aggregate {
-	return Aggregate{
-		fn:    a.fn,
-		arg:   a.arg,
-		alias: alias,
-	}
+	a.alias = alias
+	return a
 }
+
+// Distinct 对去重之后的值聚合，例如 SUM(DISTINCT `age`)
+func (a Aggregate) Distinct() Aggregate {
+	a.distinct = true
+	return a
+}
+
 func (a Aggregate) EQ(arg any) Predicate { // = 等于
 	return Predicate{
 		left:  a,
@@ -57,6 +69,13 @@ func Avg(c string) Aggregate {
 	}
 }
 
+func Min(c string) Aggregate {
+	return Aggregate{
+		fn:  "MIN",
+		arg: c,
+	}
+}
+
 func Max(c string) Aggregate {
 	return Aggregate{
 		fn:  "MAX",
@@ -70,9 +89,79 @@ func Count(c string) Aggregate {
 		arg: c,
 	}
 }
+
+// CountAll COUNT(*)
+func CountAll() Aggregate {
+	return Aggregate{
+		fn:      "COUNT",
+		argExpr: Raw("*"),
+	}
+}
+
+// CountDistinct COUNT(DISTINCT col)
+func CountDistinct(c string) Aggregate {
+	return Aggregate{
+		fn:       "COUNT",
+		arg:      c,
+		distinct: true,
+	}
+}
+
 func Sum(c string) Aggregate {
 	return Aggregate{
 		fn:  "SUM",
 		arg: c,
 	}
 }
+
+// GroupConcat 将分组内的值用 separator 拼接起来
+// MySQL 和 SQLite 使用 GROUP_CONCAT，其它数据库使用 STRING_AGG
+func GroupConcat(c string, separator string) Aggregate {
+	return Aggregate{
+		fn:        aggGroupConcat,
+		arg:       c,
+		separator: separator,
+	}
+}
+
+// AvgOf 对表达式求平均值，例如 AvgOf(TableOf(&Order{}).C("Amount"))
+func AvgOf(e Expression) Aggregate {
+	return aggregateOf("AVG", e)
+}
+
+func MaxOf(e Expression) Aggregate {
+	return aggregateOf("MAX", e)
+}
+
+func MinOf(e Expression) Aggregate {
+	return aggregateOf("MIN", e)
+}
+
+func CountOf(e Expression) Aggregate {
+	return aggregateOf("COUNT", e)
+}
+
+func SumOf(e Expression) Aggregate {
+	return aggregateOf("SUM", e)
+}
+
+func GroupConcatOf(e Expression, separator string) Aggregate {
+	a := aggregateOf(aggGroupConcat, e)
+	a.separator = separator
+	return a
+}
+
+// aggregateOf 如果是列，那么记录列所属的表，这样在子查询中也能找到对应的列
+func aggregateOf(fn string, e Expression) Aggregate {
+	if col, ok := e.(Column); ok {
+		return Aggregate{
+			fn:    fn,
+			arg:   col.name,
+			table: col.table,
+		}
+	}
+	return Aggregate{
+		fn:      fn,
+		argExpr: e,
+	}
+}
@@ -0,0 +1,164 @@
+package morm
+
+import (
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAggregate_Build(t *testing.T) {
+	db := memoryDB(t)
+	t1 := TableOf(&TestModel{}).As("t1")
+	t2 := TableOf(&Category{}).As("t2")
+	join := t1.Join(t2).On(t1.C("Id").EQ(t2.C("Id")))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "min and max",
+			q:    NewSelector[TestModel](db).Select(Min("Age"), Max("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT MIN(`age`),MAX(`age`) FROM `test_model`;",
+			},
+		},
+		{
+			name: "count all",
+			q:    NewSelector[TestModel](db).Select(CountAll().As("cnt")),
+			wantQuery: &Query{
+				SQL: "SELECT COUNT(*) AS `cnt` FROM `test_model`;",
+			},
+		},
+		{
+			name: "distinct",
+			q:    NewSelector[TestModel](db).Select(Sum("Age").Distinct()),
+			wantQuery: &Query{
+				SQL: "SELECT SUM(DISTINCT `age`) FROM `test_model`;",
+			},
+		},
+		{
+			name: "expression",
+			q:    NewSelector[TestModel](db).Select(SumOf(C("Age").Add(1)), AvgOf(Func("LENGTH", C("FirstName")))),
+			wantQuery: &Query{
+				SQL:  "SELECT SUM(`age` + ?),AVG(LENGTH(`first_name`)) FROM `test_model`;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "joined table",
+			q: NewSelector[TestModel](db).Select(t2.C("ParentId"), MaxOf(t1.C("Age")), CountOf(t2.C("Id"))).
+				From(join).GroupBy(t2.C("ParentId")).Having(MinOf(t1.C("Age")).GT(18)),
+			wantQuery: &Query{
+				SQL: "SELECT `t2`.`parent_id`,MAX(`t1`.`age`),COUNT(`t2`.`id`) " +
+					"FROM (`test_model` AS `t1` JOIN `category` AS `t2` ON `t1`.`id` = `t2`.`id`) " +
+					"GROUP BY `t2`.`parent_id` HAVING MIN(`t1`.`age`) > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "invalid joined column",
+			q: NewSelector[TestModel](db).Select(MaxOf(t2.C("Age"))).
+				From(join),
+			wantErr: errs.NewErrUnknownField("Age"),
+		},
+		{
+			name: "subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("LastName"), Max("Age").As("max_age")).
+					GroupBy(C("LastName")).AsSubquery("sub")
+				return NewSelector[TestModel](db).Select(AvgOf(sub.C("max_age"))).From(sub)
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT AVG(`sub`.`max_age`) FROM (SELECT `last_name`,MAX(`age`) AS `max_age` FROM `test_model` GROUP BY `last_name`) AS `sub`;",
+			},
+		},
+		{
+			name: "group concat",
+			q:    NewSelector[TestModel](db).Select(GroupConcat("FirstName", ",").Distinct()).GroupBy(C("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT GROUP_CONCAT(DISTINCT `first_name` SEPARATOR ',') FROM `test_model` GROUP BY `age`;",
+			},
+		},
+		{
+			// 分隔符中的单引号需要转义
+			name: "group concat escape",
+			q:    NewSelector[TestModel](db).Select(GroupConcatOf(t1.C("FirstName"), "'")).From(t1),
+			wantQuery: &Query{
+				SQL: "SELECT GROUP_CONCAT(`t1`.`first_name` SEPARATOR '''') FROM `test_model` AS `t1`;",
+			},
+		},
+		{
+			// MySQL 中 \ 也是转义字符，结尾的 \ 不能转义掉右边的单引号
+			name: "group concat backslash",
+			q:    NewSelector[TestModel](db).Select(GroupConcat("FirstName", `\' OR 1=1 -- \`)),
+			wantQuery: &Query{
+				SQL: "SELECT GROUP_CONCAT(`first_name` SEPARATOR '\\\\'' OR 1=1 -- \\\\') FROM `test_model`;",
+			},
+		},
+		{
+			name:    "group concat nul",
+			q:       NewSelector[TestModel](db).Select(GroupConcat("FirstName", "a\x00b")),
+			wantErr: errs.NewErrInvalidLiteral("a\x00b"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestAggregate_SQLite3_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "group concat",
+			q:    NewSelector[TestModel](db).Select(GroupConcat("FirstName", ";")),
+			wantQuery: &Query{
+				SQL: "SELECT GROUP_CONCAT(`first_name`,';') FROM `test_model`;",
+			},
+		},
+		{
+			name: "group concat distinct",
+			q:    NewSelector[TestModel](db).Select(GroupConcat("FirstName", ",").Distinct()),
+			wantQuery: &Query{
+				SQL: "SELECT GROUP_CONCAT(DISTINCT `first_name`) FROM `test_model`;",
+			},
+		},
+		{
+			name:    "group concat distinct separator",
+			q:       NewSelector[TestModel](db).Select(GroupConcat("FirstName", ";").Distinct()),
+			wantErr: errs.NewErrUnsupportedByDialect("SQLite", "GROUP_CONCAT(DISTINCT) 自定义分隔符"),
+		},
+		{
+			// SQLite 中 \ 不是转义字符
+			name: "group concat backslash",
+			q:    NewSelector[TestModel](db).Select(GroupConcat("FirstName", `\'`)),
+			wantQuery: &Query{
+				SQL: "SELECT GROUP_CONCAT(`first_name`,'\\''') FROM `test_model`;",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
@@ -15,9 +15,26 @@ type builder struct {
 	table   TableReference // todo builder
 	quoter  byte
 	dialect Dialect
+	// shardTable 分库分表改写之后的表名
+	shardTable string
 	core
 }
 
+// reset 丢弃上一次构造的结果，保证多次调用 Build 得到的结果是一样的
+// 上一次返回的 Query 不受影响
+func (b *builder) reset() {
+	b.sqlBuilder = strings.Builder{}
+	b.args = nil
+}
+
+// tableName 模型对应的表名，分库分表的时候使用改写之后的表名
+func (b *builder) tableName(m *model.Model) string {
+	if b.shardTable != "" && m == b.model {
+		return b.shardTable
+	}
+	return m.TableName
+}
+
 func (b *builder) quote(name string) {
 	b.sqlBuilder.WriteByte(b.quoter)
 	b.sqlBuilder.WriteString(name)
@@ -49,6 +66,23 @@ func (b *builder) buildExpression(e Expression) error {
 	case value: // 代表是列名，直接拼接列名
 		b.sqlBuilder.WriteByte('?')
 		b.args = append(b.args, exp.val)
+	case values:
+		if len(exp.vals) == 0 {
+			return errs.NewErrEmptyInValues(exp.field)
+		}
+		b.sqlBuilder.WriteByte('(')
+		for i, val := range exp.vals {
+			// Param 只占一个位置，不能在执行的时候传入多个值
+			if _, ok := val.(param); ok {
+				return errs.NewErrParamInValues(exp.field)
+			}
+			if i > 0 {
+				b.sqlBuilder.WriteByte(',')
+			}
+			b.sqlBuilder.WriteByte('?')
+		}
+		b.sqlBuilder.WriteByte(')')
+		b.addArgs(exp.vals...)
 	case RawExpr:
 		b.raw(exp)
 	case MathExpr:
@@ -63,6 +97,18 @@ func (b *builder) buildExpression(e Expression) error {
 		return b.buildBinaryExpr(binaryExpr(exp))
 	case Aggregate:
 		return b.buildAggregate(exp, false)
+	case WindowFunc:
+		return b.buildWindowFunc(exp, false)
+	case CaseExpr:
+		return b.buildCase(exp, false)
+	case FuncExpr:
+		return b.buildFunc(exp, false)
+	case MatchExpr:
+		return b.buildMatch(exp, false)
+	case JSONExpr:
+		return b.buildJSON(exp, false)
+	case AliasExpr:
+		b.quote(exp.alias)
 	default:
 		return errs.NewErrUnsupportedExpressionType(exp)
 	}
@@ -156,6 +202,12 @@ func (b *builder) colName(table TableReference, fd string) (string, error) {
 			return "", errs.NewErrUnknownField(fd)
 		}
 		return fdMeta.ColName, nil
+	case CTE:
+		if tab.sub == nil {
+			// 递归 CTE 对自身的引用
+			return b.colName(nil, fd)
+		}
+		return b.colName(*tab.sub, fd)
 	case Subquery:
 		if len(tab.columns) > 0 {
 			for _, col := range tab.columns {
@@ -176,20 +228,35 @@ func (b *builder) colName(table TableReference, fd string) (string, error) {
 
 // 构建聚合
 func (b *builder) buildAggregate(val Aggregate, useAlias bool) error {
-	b.sqlBuilder.WriteString(val.fn)
-	b.sqlBuilder.WriteString("(`")
-	fd, ok := b.model.FieldMap[val.arg]
-	if !ok {
-		return errs.NewErrUnknownField(val.arg)
-	}
-	b.sqlBuilder.WriteString(fd.ColName)
-	b.sqlBuilder.WriteString("`)")
+	if val.fn == aggGroupConcat {
+		if err := b.dialect.buildGroupConcat(b, val); err != nil {
+			return err
+		}
+	} else {
+		b.sqlBuilder.WriteString(val.fn)
+		b.sqlBuilder.WriteString("(")
+		if val.distinct {
+			b.sqlBuilder.WriteString("DISTINCT ")
+		}
+		if err := b.buildAggregateArg(val); err != nil {
+			return err
+		}
+		b.sqlBuilder.WriteString(")")
+	}
 	if useAlias {
 		b.buildAs(val.alias)
 	}
 	return nil
 }
 
+// buildAggregateArg 构建聚合函数的参数，列会按照聚合函数所属的表来解析
+func (b *builder) buildAggregateArg(val Aggregate) error {
+	if val.argExpr != nil {
+		return b.buildExpression(val.argExpr)
+	}
+	return b.buildColumn(val.table, val.arg)
+}
+
 // 构建别名
 func (b *builder) buildAs(alias string) {
 	if alias != "" {
@@ -200,6 +267,77 @@ func (b *builder) buildAs(alias string) {
 	}
 }
 
+// literal 输出字符串字面量，用于不允许使用占位符的地方，例如 GROUP_CONCAT 的 SEPARATOR
+// 不同的数据库转义的规则不一样，所以交给方言处理
+func (b *builder) literal(val string) error {
+	return b.dialect.buildLiteral(b, val)
+}
+
+// 构建 CASE WHEN
+func (b *builder) buildCase(c CaseExpr, useAlias bool) error {
+	b.sqlBuilder.WriteString("CASE")
+	for _, when := range c.whens {
+		b.sqlBuilder.WriteString(" WHEN ")
+		if err := b.buildExpression(when.cond); err != nil {
+			return err
+		}
+		b.sqlBuilder.WriteString(" THEN ")
+		if err := b.buildExpression(when.val); err != nil {
+			return err
+		}
+	}
+	if c.els != nil {
+		b.sqlBuilder.WriteString(" ELSE ")
+		if err := b.buildExpression(c.els); err != nil {
+			return err
+		}
+	}
+	b.sqlBuilder.WriteString(" END")
+	if useAlias {
+		b.buildAs(c.alias)
+	}
+	return nil
+}
+
+// 构建函数调用
+func (b *builder) buildFunc(f FuncExpr, useAlias bool) error {
+	b.sqlBuilder.WriteString(f.fn)
+	b.sqlBuilder.WriteByte('(')
+	for i, arg := range f.args {
+		if i > 0 {
+			b.sqlBuilder.WriteByte(',')
+		}
+		if err := b.buildExpression(arg); err != nil {
+			return err
+		}
+	}
+	b.sqlBuilder.WriteByte(')')
+	if useAlias {
+		b.buildAs(f.alias)
+	}
+	return nil
+}
+
+// buildOrderBys 构建 ORDER BY 后面的部分
+func (b *builder) buildOrderBys(orderBys []OrderBy) error {
+	for i, order := range orderBys {
+		if i > 0 {
+			b.sqlBuilder.WriteByte(',')
+		}
+		if err := b.buildExpression(order.expr); err != nil {
+			return err
+		}
+		b.sqlBuilder.WriteByte(' ')
+		b.sqlBuilder.WriteString(order.fun)
+		if order.nulls != "" {
+			if err := b.dialect.buildNullsOrder(b, order.nulls == nullsFirst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (b *builder) addArgs(args ...any) {
 	if b.args == nil {
 		b.args = make([]any, 0, 8)
@@ -0,0 +1,211 @@
+package morm
+
+import (
+	"context"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/NotFound1911/morm/internal/valuer"
+	"github.com/NotFound1911/morm/model"
+)
+
+// BulkUpdater 用一条语句将多行更新为各自不同的值
+// UPDATE `t` SET `col`=CASE `pk` WHEN ? THEN ? ... END WHERE `pk` IN (?,...);
+type BulkUpdater[T any] struct {
+	builder
+	values  []*T
+	columns []string
+	sess    session
+}
+
+func NewBulkUpdater[T any](sess session) *BulkUpdater[T] {
+	c := sess.getCore()
+	return &BulkUpdater[T]{
+		sess: sess,
+		builder: builder{
+			core:    c,
+			dialect: c.dialect,
+			quoter:  c.dialect.quoter(),
+		},
+	}
+}
+
+// Values 要更新的数据，通过主键定位行
+func (u *BulkUpdater[T]) Values(vals ...*T) *BulkUpdater[T] {
+	u.values = vals
+	return u
+}
+
+// Columns 要更新的字段，没有指定的时候更新主键以外的所有字段
+func (u *BulkUpdater[T]) Columns(cols ...string) *BulkUpdater[T] {
+	u.columns = cols
+	return u
+}
+
+func (u *BulkUpdater[T]) Build() (*Query, error) {
+	u.reset()
+	if len(u.values) == 0 {
+		return nil, errs.NewErrUpdateZeroRow()
+	}
+	var (
+		t   T
+		err error
+	)
+	u.model, err = u.r.Get(&t)
+	if err != nil {
+		return nil, err
+	}
+	pk := u.model.PrimaryKey
+	if pk == nil {
+		return nil, errs.NewErrMissingPrimaryKey(u.model.TableName)
+	}
+	fields, err := u.fields(pk)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errs.NewErrNoUpdatedColumns()
+	}
+	vals := make([]valuer.Value, 0, len(u.values))
+	ids := make([]any, 0, len(u.values))
+	for _, val := range u.values {
+		refVal := u.valCreator(val, u.model)
+		id, err := refVal.Field(pk.GoName)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, refVal)
+		ids = append(ids, id)
+	}
+	u.args = make([]any, 0, len(u.values)*(len(fields)*2+1))
+	u.sqlBuilder.WriteString("UPDATE ")
+	u.quote(u.tableName(u.model))
+	u.sqlBuilder.WriteString(" SET ")
+	for fIdx, fd := range fields {
+		if fIdx > 0 {
+			u.sqlBuilder.WriteByte(',')
+		}
+		u.quote(fd.ColName)
+		u.sqlBuilder.WriteString("=CASE ")
+		u.quote(pk.ColName)
+		for vIdx, val := range vals {
+			fdVal, err := val.Field(fd.GoName)
+			if err != nil {
+				return nil, err
+			}
+			u.sqlBuilder.WriteString(" WHEN ? THEN ?")
+			u.addArgs(ids[vIdx], fdVal)
+		}
+		u.sqlBuilder.WriteString(" END")
+	}
+	u.sqlBuilder.WriteString(" WHERE ")
+	u.quote(pk.ColName)
+	u.sqlBuilder.WriteString(" IN (")
+	for idx, id := range ids {
+		if idx > 0 {
+			u.sqlBuilder.WriteByte(',')
+		}
+		u.sqlBuilder.WriteByte('?')
+		u.addArgs(id)
+	}
+	u.sqlBuilder.WriteString(");")
+	return &Query{
+		SQL:  u.sqlBuilder.String(),
+		Args: u.args,
+	}, nil
+}
+
+// fields 返回要更新的字段，主键不允许更新
+func (u *BulkUpdater[T]) fields(pk *model.Field) ([]*model.Field, error) {
+	if len(u.columns) == 0 {
+		fields := make([]*model.Field, 0, len(u.model.Fields))
+		for _, fd := range u.model.Fields {
+			if fd != pk {
+				fields = append(fields, fd)
+			}
+		}
+		return fields, nil
+	}
+	fields := make([]*model.Field, 0, len(u.columns))
+	for _, col := range u.columns {
+		fd, ok := u.model.FieldMap[col]
+		if !ok {
+			return nil, errs.NewErrUnknownField(col)
+		}
+		if fd == pk {
+			return nil, errs.NewErrUpdatePrimaryKey(col)
+		}
+		fields = append(fields, fd)
+	}
+	return fields, nil
+}
+
+// Exec 执行更新，占位符的数量超过方言的上限的时候拆分成多条语句，
+// 多条语句在同一个事务中执行：如果 BulkUpdater 是在事务中创建的，或者 ctx 中已经有事务，直接使用该事务
+func (u *BulkUpdater[T]) Exec(ctx context.Context) Result {
+	if sd, ok := u.sess.(*ShardingDB); ok {
+		return shardingBulkUpdate[T](ctx, sd, u)
+	}
+	var (
+		t   T
+		err error
+	)
+	u.model, err = u.r.Get(&t)
+	if err != nil {
+		return Result{err: err}
+	}
+	size := u.batchRows()
+	if len(u.values) <= size {
+		return exec(ctx, u.sess, u.core, &QueryContext{Builder: u, Type: "UPDATE"})
+	}
+	db, ok := u.sess.(*DB)
+	if !ok {
+		return u.execBatches(ctx, u.sess, size)
+	}
+	if _, inTx := db.TxFromContext(ctx); inTx {
+		return u.execBatches(ctx, db, size)
+	}
+	var res Result
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		res = u.execBatches(ctx, tx, size)
+		return res.err
+	}, nil)
+	if err != nil {
+		res.err = err
+	}
+	return res
+}
+
+// batchRows 计算每条语句更新的行数，每一行占用 2 * 字段数 + 1 个占位符
+// 没有主键或者字段不合法的时候不拆分，由 Build 返回错误
+func (u *BulkUpdater[T]) batchRows() int {
+	pk := u.model.PrimaryKey
+	if pk == nil {
+		return len(u.values)
+	}
+	fields, err := u.fields(pk)
+	if err != nil || len(fields) == 0 {
+		return len(u.values)
+	}
+	rows := u.dialect.maxParams() / (len(fields)*2 + 1)
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+func (u *BulkUpdater[T]) execBatches(ctx context.Context, sess session, size int) Result {
+	var sum sqlResult
+	for start := 0; start < len(u.values); start += size {
+		end := start + size
+		if end > len(u.values) {
+			end = len(u.values)
+		}
+		sub := NewBulkUpdater[T](sess).Values(u.values[start:end]...).Columns(u.columns...)
+		sub.shardTable = u.shardTable
+		affected, err := sub.Exec(ctx).RowsAffected()
+		if err != nil {
+			return Result{err: err, res: sum}
+		}
+		sum.rowsAffected += affected
+	}
+	return Result{res: sum}
+}
@@ -0,0 +1,204 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBulkUpdater_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name    string
+		u       QueryBuilder
+		want    *Query
+		wantErr error
+	}{
+		{
+			name:    "no value",
+			u:       NewBulkUpdater[TestModel](db),
+			wantErr: errs.NewErrUpdateZeroRow(),
+		},
+		{
+			name: "specify columns",
+			u: NewBulkUpdater[TestModel](db).Values(
+				&TestModel{Id: 1, FirstName: "a", Age: 18},
+				&TestModel{Id: 2, FirstName: "b", Age: 19},
+			).Columns("FirstName", "Age"),
+			want: &Query{
+				SQL: "UPDATE `test_model` SET " +
+					"`first_name`=CASE `id` WHEN ? THEN ? WHEN ? THEN ? END," +
+					"`age`=CASE `id` WHEN ? THEN ? WHEN ? THEN ? END " +
+					"WHERE `id` IN (?,?);",
+				Args: []any{int64(1), "a", int64(2), "b", int64(1), int8(18), int64(2), int8(19), int64(1), int64(2)},
+			},
+		},
+		{
+			// 默认更新主键以外的所有列
+			name: "all columns",
+			u: NewBulkUpdater[TestModel](db).Values(
+				&TestModel{Id: 1, FirstName: "a", Age: 18},
+			),
+			want: &Query{
+				SQL: "UPDATE `test_model` SET " +
+					"`first_name`=CASE `id` WHEN ? THEN ? END," +
+					"`age`=CASE `id` WHEN ? THEN ? END," +
+					"`last_name`=CASE `id` WHEN ? THEN ? END " +
+					"WHERE `id` IN (?);",
+				Args: []any{int64(1), "a", int64(1), int8(18), int64(1), (*sql.NullString)(nil), int64(1)},
+			},
+		},
+		{
+			name: "invalid column",
+			u: NewBulkUpdater[TestModel](db).Values(
+				&TestModel{Id: 1, FirstName: "a", Age: 18},
+			).Columns("Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "update primary key",
+			u: NewBulkUpdater[TestModel](db).Values(
+				&TestModel{Id: 1, FirstName: "a", Age: 18},
+			).Columns("Id"),
+			wantErr: errs.NewErrUpdatePrimaryKey("Id"),
+		},
+		{
+			name: "no primary key",
+			u: func() QueryBuilder {
+				type NoPrimaryKey struct {
+					Name string
+				}
+				return NewBulkUpdater[NoPrimaryKey](db).Values(&NoPrimaryKey{Name: "a"})
+			}(),
+			wantErr: errs.NewErrMissingPrimaryKey("no_primary_key"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.u.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, q)
+		})
+	}
+}
+
+func TestBulkUpdater_Exec(t *testing.T) {
+	db := memoryDBWithDB("bulk_updater", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	vals := []*TestModel{
+		{FirstName: "a", Age: 18, LastName: &sql.NullString{String: "A", Valid: true}},
+		{FirstName: "b", Age: 19, LastName: &sql.NullString{String: "B", Valid: true}},
+		{FirstName: "c", Age: 20, LastName: &sql.NullString{String: "C", Valid: true}},
+	}
+	res := NewInserter[TestModel](db).Values(vals...).Exec(context.Background())
+	require.NoError(t, res.Err())
+
+	vals[0].Age, vals[1].Age = 28, 29
+	vals[0].FirstName = "aa"
+	res = NewBulkUpdater[TestModel](db).Values(vals[0], vals[1]).Columns("Age").Exec(context.Background())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	got, err := NewSelector[TestModel](db).OrderBy(Asc("Id")).GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int8{28, 29, 20}, []int8{got[0].Age, got[1].Age, got[2].Age})
+	// 没有指定的列不会被更新
+	assert.Equal(t, "a", got[0].FirstName)
+}
+
+func TestBulkUpdater_batchRows(t *testing.T) {
+	db := memoryDB(t)
+	sqliteDB := memoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name     string
+		u        *BulkUpdater[TestModel]
+		wantRows int
+	}{
+		{
+			name:     "mysql",
+			u:        NewBulkUpdater[TestModel](db).Values(&TestModel{}),
+			wantRows: 65535 / 7,
+		},
+		{
+			name:     "sqlite3 columns",
+			u:        NewBulkUpdater[TestModel](sqliteDB).Values(&TestModel{}).Columns("Age"),
+			wantRows: 32766 / 3,
+		},
+		{
+			// 字段不合法的时候不拆分
+			name:     "invalid column",
+			u:        NewBulkUpdater[TestModel](db).Values(&TestModel{}, &TestModel{}).Columns("Id"),
+			wantRows: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			tc.u.model, err = tc.u.r.Get(&TestModel{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantRows, tc.u.batchRows())
+		})
+	}
+}
+
+func TestBulkUpdater_ExecBatches(t *testing.T) {
+	// 每条语句最多 32766 / 3 行
+	size := 32766 / 3
+	vals := make([]*TestModel, 0, size+1)
+	for i := 1; i <= size+1; i++ {
+		vals = append(vals, &TestModel{Id: int64(i), Age: 18})
+	}
+	testCases := []struct {
+		name    string
+		mock    func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "commit",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, int64(size)))
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, int64(size)))
+				mock.ExpectExec("UPDATE .*").WillReturnError(errors.New("mock error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("mock error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB, DBWithDialect(SQLite3))
+			require.NoError(t, err)
+			tc.mock(mock)
+			res := NewBulkUpdater[TestModel](db).Values(vals...).Columns("Age").Exec(context.Background())
+			affected, err := res.RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			if err != nil {
+				return
+			}
+			assert.Equal(t, int64(size+1), affected)
+		})
+	}
+}
@@ -26,6 +26,7 @@ func (c Column) assign() {}
 
 func (c Column) As(alias string) Column {
 	return Column{
+		table: c.table,
 		name:  c.name,
 		alias: alias,
 	}
@@ -98,6 +99,27 @@ func (c Column) GT(arg any) Predicate { // > 大于
 		right: exprOf(arg),
 	}
 }
+func (c Column) NEQ(arg any) Predicate { // != 不等于
+	return Predicate{
+		left:  c,
+		opt:   optNEQ,
+		right: exprOf(arg),
+	}
+}
+func (c Column) LTEQ(arg any) Predicate { // <= 小于等于
+	return Predicate{
+		left:  c,
+		opt:   optLTEQ,
+		right: exprOf(arg),
+	}
+}
+func (c Column) GTEQ(arg any) Predicate { // >= 大于等于
+	return Predicate{
+		left:  c,
+		opt:   optGTEQ,
+		right: exprOf(arg),
+	}
+}
 
 // InQuery 一种是 IN 子查询, 另外一种就是普通的值
 func (c Column) InQuery(sub Subquery) Predicate {
@@ -107,3 +129,44 @@ func (c Column) InQuery(sub Subquery) Predicate {
 		right: sub,
 	}
 }
+
+// In C("Id").In(1, 2, 3)，没有任何值的时候构造 SQL 会返回错误
+func (c Column) In(vals ...any) Predicate {
+	return Predicate{
+		left:  c,
+		opt:   optIN,
+		right: valuesOf(c.name, vals),
+	}
+}
+
+func (c Column) IsNull() Predicate {
+	return Predicate{
+		left: c,
+		opt:  optIsNull,
+	}
+}
+
+func (c Column) IsNotNull() Predicate {
+	return Predicate{
+		left: c,
+		opt:  optIsNotNull,
+	}
+}
+
+// values 代表 IN 后面的值列表，构造为 (?,?,?)
+type values struct {
+	// field 用于错误信息
+	field string
+	vals  []any
+}
+
+var _ Expression = values{}
+
+func (values) expr() {}
+
+func valuesOf(field string, vals []any) values {
+	return values{
+		field: field,
+		vals:  vals,
+	}
+}
@@ -0,0 +1,116 @@
+package morm
+
+import (
+	"context"
+	"github.com/NotFound1911/morm/errors"
+)
+
+// param 预编译查询中的参数占位符
+type param struct{}
+
+// Param 在预编译的查询中代表一个参数，执行的时候按照出现的顺序传入参数
+// 例如 NewSelector[User](db).Where(C("Id").EQ(Param())).Compile()
+// 只能用于 Compile，直接执行带有 Param 的查询，驱动会返回错误
+// 不能用于 In，因为 IN 中值的数量在编译的时候就确定了
+func Param() param {
+	return param{}
+}
+
+// Prepared 预编译的查询，SQL 只构造一次，每次执行只替换参数
+// 可以在多个 goroutine 中同时使用
+type Prepared[T any] struct {
+	s *Selector[T]
+	q *Query
+	// params Param 在参数中的下标
+	params []int
+}
+
+// Compile 构造 SQL 并且记录 Param 的位置，返回可以重复执行的查询
+// 之后对 Selector 的修改不会影响返回的 Prepared
+func (s *Selector[T]) Compile() (*Prepared[T], error) {
+	if _, ok := s.sess.(*ShardingDB); ok {
+		return nil, errs.NewErrShardingNotSupported("预编译查询")
+	}
+	sel := s.Clone()
+	q, err := sel.Build()
+	if err != nil {
+		return nil, err
+	}
+	var params []int
+	for i, arg := range q.Args {
+		if _, ok := arg.(param); ok {
+			params = append(params, i)
+		}
+	}
+	return &Prepared[T]{s: sel, q: q, params: params}, nil
+}
+
+// bind 用 args 替换 Param，得到这一次执行的查询
+func (p *Prepared[T]) bind(args []any) (*Query, error) {
+	if len(args) != len(p.params) {
+		return nil, errs.NewErrParamCount(len(p.params), len(args))
+	}
+	if len(args) == 0 {
+		return p.q, nil
+	}
+	bound := make([]any, len(p.q.Args))
+	copy(bound, p.q.Args)
+	for i, idx := range p.params {
+		bound[idx] = args[i]
+	}
+	return &Query{SQL: p.q.SQL, Args: bound}, nil
+}
+
+// Build 返回的 SQL 中 Param 还没有被替换
+func (p *Prepared[T]) Build() (*Query, error) {
+	return p.q, nil
+}
+
+func (p *Prepared[T]) Get(ctx context.Context, args ...any) (*T, error) {
+	qc, err := p.queryContext(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.s.checkLock(ctx); err != nil {
+		return nil, err
+	}
+	if p.s.primary {
+		ctx = UsePrimary(ctx)
+	}
+	res := get[T](ctx, p.s.core, p.s.sess, qc)
+	if res.Result != nil {
+		return res.Result.(*T), res.Err
+	}
+	return nil, res.Err
+}
+
+func (p *Prepared[T]) GetMulti(ctx context.Context, args ...any) ([]*T, error) {
+	qc, err := p.queryContext(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.s.checkLock(ctx); err != nil {
+		return nil, err
+	}
+	if p.s.primary {
+		ctx = UsePrimary(ctx)
+	}
+	res := getMulti[T](ctx, p.s.core, p.s.sess, qc)
+	if res.Result != nil {
+		return res.Result.([]*T), res.Err
+	}
+	return nil, res.Err
+}
+
+func (p *Prepared[T]) queryContext(args []any) (*QueryContext, error) {
+	q, err := p.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	// 直接放入构造的结果，中间件拿到的就是替换了参数的查询
+	return &QueryContext{
+		Type:    "SELECT",
+		Builder: p,
+		q:       q,
+	}, nil
+}
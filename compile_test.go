@@ -0,0 +1,186 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSelector_Compile(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	var queries []*Query
+	db, err := OpenDB(mockDB, DBWithMiddleware(func(next HanderFunc) HanderFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			q, err := qc.Query()
+			if err != nil {
+				return &QueryResult{Err: err}
+			}
+			queries = append(queries, q)
+			return next(ctx, qc)
+		}
+	}))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	s := NewSelector[TestModel](db).Where(C("Age").GT(18), C("Id").EQ(Param())).Limit(10)
+	p, err := s.Compile()
+	require.NoError(t, err)
+	// 之后修改 Selector 不影响已经编译的查询
+	s.Where(C("FirstName").EQ("Tom"))
+
+	wantSQL := "SELECT * FROM `test_model` WHERE (`age` > ?) AND (`id` = ?) LIMIT ?;"
+	q, err := p.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{SQL: wantSQL, Args: []any{18, Param(), 10}}, q)
+
+	rows := func(id int64) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id"}).AddRow(id)
+	}
+	mock.ExpectQuery(wantSQL).WithArgs(18, 1, 10).WillReturnRows(rows(1))
+	mock.ExpectQuery(wantSQL).WithArgs(18, 2, 10).WillReturnRows(rows(2))
+	mock.ExpectQuery(wantSQL).WithArgs(18, 3, 10).WillReturnRows(rows(3))
+
+	tm, err := p.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), tm.Id)
+	tm, err = p.Get(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), tm.Id)
+	tms, err := p.GetMulti(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{{Id: 3}}, tms)
+
+	// 中间件拿到的是替换了参数的查询，编译的模板不受影响
+	assert.Equal(t, []*Query{
+		{SQL: wantSQL, Args: []any{18, 1, 10}},
+		{SQL: wantSQL, Args: []any{18, 2, 10}},
+		{SQL: wantSQL, Args: []any{18, 3, 10}},
+	}, queries)
+	assert.Equal(t, []any{18, Param(), 10}, q.Args)
+
+	_, err = p.Get(ctx)
+	assert.Equal(t, errs.NewErrParamCount(1, 0), err)
+	_, err = p.GetMulti(ctx, 1, 2)
+	assert.Equal(t, errs.NewErrParamCount(1, 2), err)
+
+	p, err = NewSelector[TestModel](db).Where(C("Id").EQ(Param())).ForUpdate().Compile()
+	require.NoError(t, err)
+	_, err = p.Get(ctx, 1)
+	assert.Equal(t, errs.NewErrLockOutsideTx("FOR UPDATE"), err)
+
+	_, err = NewSelector[TestModel](db).Where(C("Invalid").EQ(Param())).Compile()
+	assert.Equal(t, errs.NewErrUnknownField("Invalid"), err)
+	_, err = NewSelector[TestModel](db).Where(C("Id").In(1, Param())).Compile()
+	assert.Equal(t, errs.NewErrParamInValues("Id"), err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPrepared_SQLite(t *testing.T) {
+	db := memoryDBWithDB("prepared_sqlite", t, DBWithDialect(SQLite3), DBWithStmtCache(8))
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	ctx := context.Background()
+	for i := int64(1); i <= 3; i++ {
+		res := NewInserter[TestModel](db).Values(&TestModel{
+			Id: i, FirstName: "Tom", Age: int8(i * 10), LastName: &sql.NullString{String: "Jerry", Valid: true},
+		}).Exec(ctx)
+		require.NoError(t, res.Err())
+	}
+
+	before := db.StmtCacheStats()
+	p, err := NewSelector[TestModel](db).Where(C("Age").GTEQ(Param())).OrderBy(Asc("Id")).Compile()
+	require.NoError(t, err)
+	for _, age := range []int8{10, 20, 30} {
+		tms, err := p.GetMulti(ctx, age)
+		require.NoError(t, err)
+		assert.Equal(t, 4-int(age/10), len(tms))
+		assert.Equal(t, age, tms[0].Age)
+	}
+	_, err = p.Get(ctx, 40)
+	assert.Equal(t, sql.ErrNoRows, err)
+	// 同样的 SQL，只会预编译一次
+	after := db.StmtCacheStats()
+	assert.Equal(t, uint64(3), after.Hits-before.Hits)
+	assert.Equal(t, uint64(1), after.Misses-before.Misses)
+}
+
+// 执行 go test -bench=BenchmarkSelector_Compile -benchmem
+// 比较每次构造 SQL 和使用预编译查询只替换参数，两者执行的是同一条 SQL
+// goos: linux
+// goarch: amd64
+// pkg: github.com/NotFound1911/morm
+// cpu: Intel(R) Xeon(R) Processor
+// BenchmarkSelector_Compile/build                106258             11756 ns/op            2752 B/op         59 allocs/op
+// BenchmarkSelector_Compile/compile              119028             10120 ns/op            1664 B/op         42 allocs/op
+// BenchmarkSelector_Compile/build_only           760659              1419 ns/op            1336 B/op         21 allocs/op
+// BenchmarkSelector_Compile/bind_only          13136912             92.03 ns/op              96 B/op          2 allocs/op
+// PASS
+func BenchmarkSelector_Compile(b *testing.B) {
+	db, err := Open("sqlite3", "file:benchmark_compile.db?cache=shared&mode=memory", DBWithDialect(SQLite3))
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, err = db.db.Exec(TestModel{}.CreateSQL())
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, err = db.db.Exec("INSERT INTO `test_model`(`id`, `first_name`, `age`, `last_name`) VALUES (?,?,?,?)",
+		1, "aa", 18, "bb")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	b.Run("build", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err = NewSelector[TestModel](db).Where(C("Id").EQ(int64(1)), C("Age").GT(10)).
+				OrderBy(Asc("Id")).Limit(1).GetMulti(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("compile", func(b *testing.B) {
+		p, err := NewSelector[TestModel](db).Where(C("Id").EQ(Param()), C("Age").GT(Param())).
+			OrderBy(Asc("Id")).Limit(1).Compile()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err = p.GetMulti(ctx, int64(1), 10)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// 只比较构造 SQL 的开销
+	b.Run("build only", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err = NewSelector[TestModel](db).Where(C("Id").EQ(int64(1)), C("Age").GT(10)).
+				OrderBy(Asc("Id")).Limit(1).Build()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("bind only", func(b *testing.B) {
+		p, err := NewSelector[TestModel](db).Where(C("Id").EQ(Param()), C("Age").GT(Param())).
+			OrderBy(Asc("Id")).Limit(1).Compile()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err = p.bind([]any{int64(1), 10})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -29,7 +29,7 @@ type core struct {
 }
 
 func getMultiHandler[T any](ctx context.Context, c core, sess session, qc *QueryContext) *QueryResult {
-	q, err := qc.Builder.Build()
+	q, err := qc.Query()
 	if err != nil {
 		return &QueryResult{
 			Err: err,
@@ -70,7 +70,7 @@ func getMultiHandler[T any](ctx context.Context, c core, sess session, qc *Query
 	}
 }
 func getHandler[T any](ctx context.Context, c core, sess session, qc *QueryContext) *QueryResult {
-	q, err := qc.Builder.Build()
+	q, err := qc.Query()
 	if err != nil {
 		return &QueryResult{
 			Err: err,
@@ -122,9 +122,38 @@ func getMulti[T any](ctx context.Context, c core, sess session, qc *QueryContext
 	}
 	return handler(ctx, qc)
 }
+
+// queryRows 经过中间件执行查询，返回的 *sql.Rows 由调用者关闭
+// 用于分库分表合并结果这种需要自己读取结果集的场景
+func queryRows(ctx context.Context, c core, sess session, qc *QueryContext) (*sql.Rows, error) {
+	var handler HanderFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Query()
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		rows, err := sess.queryContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Err: err, Result: rows}
+	}
+	ms := c.ms
+	for i := len(ms) - 1; i >= 0; i-- {
+		handler = ms[i](handler)
+	}
+	qr := handler(ctx, qc)
+	rows, _ := qr.Result.(*sql.Rows)
+	if qr.Err != nil {
+		if rows != nil {
+			_ = rows.Close()
+		}
+		return nil, qr.Err
+	}
+	return rows, nil
+}
+
 func exec(ctx context.Context, sess session, c core, qc *QueryContext) Result {
 	var handler HanderFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
-		q, err := qc.Builder.Build()
+		q, err := qc.Query()
 		if err != nil {
 			return &QueryResult{
 				Err: err,
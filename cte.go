@@ -0,0 +1,100 @@
+package morm
+
+// SubqueryBuilder 可以转化为子查询的查询，例如 Selector 和 SetOperation
+type SubqueryBuilder interface {
+	AsSubquery(alias string) Subquery
+}
+
+var _ TableReference = CTE{}
+
+// CTE 公共表表达式，即 WITH name AS (...)
+// CTE 可以作为 TableReference 使用，列的解析方式和 Subquery 一致
+type CTE struct {
+	name      string
+	recursive bool
+	// sub 为空代表这是递归 CTE 在定义中对自身的引用
+	sub *Subquery
+}
+
+// With 定义一个 CTE
+func With(name string, q SubqueryBuilder) CTE {
+	sub := q.AsSubquery(name)
+	return CTE{
+		name: name,
+		sub:  &sub,
+	}
+}
+
+// WithRecursive 定义一个递归 CTE
+// fn 的参数是 CTE 对自身的引用，可以在递归部分的 FROM 或者 JOIN 中使用，
+// 它的列按照使用它的查询的模型来解析
+func WithRecursive(name string, fn func(self CTE) SubqueryBuilder) CTE {
+	self := CTE{
+		name:      name,
+		recursive: true,
+	}
+	sub := fn(self).AsSubquery(name)
+	self.sub = &sub
+	return self
+}
+
+func (c CTE) tableAlias() string {
+	return c.name
+}
+
+func (c CTE) C(name string) Column {
+	return Column{
+		table: c,
+		name:  name,
+	}
+}
+
+func (c CTE) Join(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "JOIN",
+	}
+}
+
+func (c CTE) LeftJoin(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "LEFT JOIN",
+	}
+}
+
+func (c CTE) RightJoin(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "RIGHT JOIN",
+	}
+}
+
+// buildWith 构造 WITH 部分，只要有一个 CTE 是递归的就需要使用 WITH RECURSIVE
+func (b *builder) buildWith(ctes []CTE) error {
+	if len(ctes) == 0 {
+		return nil
+	}
+	b.sqlBuilder.WriteString("WITH ")
+	for _, cte := range ctes {
+		if cte.recursive {
+			b.sqlBuilder.WriteString("RECURSIVE ")
+			break
+		}
+	}
+	for i, cte := range ctes {
+		if i > 0 {
+			b.sqlBuilder.WriteByte(',')
+		}
+		b.quote(cte.name)
+		b.sqlBuilder.WriteString(" AS ")
+		if err := b.buildSubquery(*cte.sub, false); err != nil {
+			return err
+		}
+	}
+	b.sqlBuilder.WriteByte(' ')
+	return nil
+}
@@ -0,0 +1,158 @@
+package morm
+
+import (
+	"context"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type Category struct {
+	Id       int64
+	ParentId int64
+	Name     string
+}
+
+func (Category) CreateSQL() string {
+	return `
+CREATE TABLE IF NOT EXISTS category(
+    id INTEGER PRIMARY KEY,
+    parent_id INTEGER NOT NULL,
+    name TEXT NOT NULL
+)
+`
+}
+
+func TestCTE_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "with",
+			q: func() QueryBuilder {
+				adult := With("adult", NewSelector[TestModel](db).
+					Select(C("Id"), C("Age")).Where(C("Age").GT(18)))
+				return NewSelector[TestModel](db).With(adult).
+					Select(adult.C("Id")).From(adult).Where(adult.C("Age").LT(60))
+			}(),
+			wantQuery: &Query{
+				SQL: "WITH `adult` AS (SELECT `id`,`age` FROM `test_model` WHERE `age` > ?) " +
+					"SELECT `adult`.`id` FROM `adult` WHERE `adult`.`age` < ?;",
+				Args: []any{18, 60},
+			},
+		},
+		{
+			name: "multiple with",
+			q: func() QueryBuilder {
+				adult := With("adult", NewSelector[TestModel](db).Where(C("Age").GT(18)))
+				child := With("child", NewSelector[TestModel](db).Where(C("Age").LT(10)))
+				return NewSelector[TestModel](db).With(adult, child).
+					From(adult.Join(child).On(adult.C("LastName").EQ(child.C("LastName"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "WITH `adult` AS (SELECT * FROM `test_model` WHERE `age` > ?)," +
+					"`child` AS (SELECT * FROM `test_model` WHERE `age` < ?) " +
+					"SELECT * FROM (`adult` JOIN `child` ON `adult`.`last_name` = `child`.`last_name`);",
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name: "invalid column",
+			q: func() QueryBuilder {
+				adult := With("adult", NewSelector[TestModel](db).Select(C("Id")))
+				return NewSelector[TestModel](db).With(adult).
+					From(adult).Where(adult.C("Age").LT(60))
+			}(),
+			wantErr: errs.NewErrUnknownField("Age"),
+		},
+		{
+			name: "recursive",
+			q: func() QueryBuilder {
+				c := TableOf(&Category{}).As("c")
+				tree := WithRecursive("tree", func(self CTE) SubqueryBuilder {
+					return NewSelector[Category](db).Where(C("ParentId").EQ(0)).
+						UnionAll(NewSelector[Category](db).
+							Select(c.C("Id"), c.C("ParentId"), c.C("Name")).
+							From(c.Join(self).On(c.C("ParentId").EQ(self.C("Id")))))
+				})
+				return NewSelector[Category](db).With(tree).From(tree)
+			}(),
+			wantQuery: &Query{
+				SQL: "WITH RECURSIVE `tree` AS ((SELECT * FROM `category` WHERE `parent_id` = ?) UNION ALL " +
+					"(SELECT `c`.`id`,`c`.`parent_id`,`c`.`name` FROM (`category` AS `c` JOIN `tree` ON `c`.`parent_id` = `tree`.`id`))) " +
+					"SELECT * FROM `tree`;",
+				Args: []any{0},
+			},
+		},
+		{
+			name: "update",
+			q: func() QueryBuilder {
+				adult := With("adult", NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)))
+				return NewUpdater[TestModel](db).With(adult).Set(Assign("FirstName", "adult")).
+					Where(C("Id").InQuery(NewSelector[TestModel](db).Select(adult.C("Id")).From(adult).AsSubquery("")))
+			}(),
+			wantQuery: &Query{
+				SQL: "WITH `adult` AS (SELECT `id` FROM `test_model` WHERE `age` > ?) " +
+					"UPDATE `test_model` SET `first_name`=? WHERE `id` IN (SELECT `adult`.`id` FROM `adult`);",
+				Args: []any{18, "adult"},
+			},
+		},
+		{
+			name: "delete",
+			q: func() QueryBuilder {
+				adult := With("adult", NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)))
+				return NewDeleter[TestModel](db).With(adult).
+					Where(C("Id").InQuery(NewSelector[TestModel](db).Select(adult.C("Id")).From(adult).AsSubquery("")))
+			}(),
+			wantQuery: &Query{
+				SQL: "WITH `adult` AS (SELECT `id` FROM `test_model` WHERE `age` > ?) " +
+					"DELETE FROM `test_model` WHERE `id` IN (SELECT `adult`.`id` FROM `adult`);",
+				Args: []any{18},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestCTE_Recursive_GetMulti(t *testing.T) {
+	db := memoryDBWithDB("cte_recursive", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(Category{}.CreateSQL())
+	require.NoError(t, err)
+	res := NewInserter[Category](db).Values(
+		&Category{Id: 1, ParentId: 0, Name: "root"},
+		&Category{Id: 2, ParentId: 1, Name: "child"},
+		&Category{Id: 3, ParentId: 2, Name: "grandchild"},
+		&Category{Id: 4, ParentId: 0, Name: "other"},
+	).Exec(context.Background())
+	require.NoError(t, res.Err())
+
+	c := TableOf(&Category{}).As("c")
+	tree := WithRecursive("tree", func(self CTE) SubqueryBuilder {
+		return NewSelector[Category](db).Where(C("Id").EQ(1)).
+			UnionAll(NewSelector[Category](db).
+				Select(c.C("Id"), c.C("ParentId"), c.C("Name")).
+				From(c.Join(self).On(c.C("ParentId").EQ(self.C("Id")))))
+	})
+	got, err := NewSelector[Category](db).With(tree).From(tree).
+		OrderBy(Asc("Id")).GetMulti(context.Background())
+	require.NoError(t, err)
+	names := make([]string, 0, len(got))
+	for _, cat := range got {
+		names = append(names, cat.Name)
+	}
+	assert.Equal(t, []string{"root", "child", "grandchild"}, names)
+}
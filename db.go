@@ -14,6 +14,14 @@ import (
 type DBOption func(*DB) error
 type DB struct {
 	db *sql.DB
+	// replicas 只读副本，为空代表没有读写分离
+	replicas *replicaGroup
+	// retry 事务的重试策略，为空代表不重试
+	retry *RetryPolicy
+	// txCallbackErr 处理事务回调中的 panic
+	txCallbackErr func(ctx context.Context, err error)
+	// stmts 预编译语句的缓存，为空代表不缓存
+	stmts *stmtCache
 	core
 }
 
@@ -33,10 +41,29 @@ func (db *DB) getCore() core {
 }
 
 func (db *DB) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if tx, ok := db.TxFromContext(ctx); ok {
+		return tx.queryContext(ctx, query, args...)
+	}
+	if db.replicas != nil && !isPrimary(ctx) {
+		rows, ok, err := db.replicas.queryContext(ctx, query, args...)
+		if ok {
+			return rows, err
+		}
+		// 所有的副本都不可用，退化为使用主库
+	}
+	if db.stmts != nil {
+		return db.stmts.queryContext(ctx, db.db, query, args...)
+	}
 	return db.db.QueryContext(ctx, query, args...)
 }
 
 func (db *DB) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if tx, ok := db.TxFromContext(ctx); ok {
+		return tx.execContext(ctx, query, args...)
+	}
+	if db.stmts != nil {
+		return db.stmts.execContext(ctx, db.db, query, args...)
+	}
 	return db.db.ExecContext(ctx, query, args...)
 }
 
@@ -59,6 +86,9 @@ func OpenDB(db *sql.DB, opts ...DBOption) (*DB, error) {
 			valCreator: valuer.NewUnsafeValue,
 		},
 		db: db,
+		txCallbackErr: func(ctx context.Context, err error) {
+			log.Println(err)
+		},
 	}
 	for _, opt := range opts {
 		if err := opt(res); err != nil {
@@ -92,6 +122,14 @@ func DBWithMiddleware(ms ...Middleware) DBOption {
 	}
 }
 
+// DBWithTxCallbackErrorHandler 处理 AfterCommit 和 AfterRollback 回调中的 panic，默认输出日志
+func DBWithTxCallbackErrorHandler(fn func(ctx context.Context, err error)) DBOption {
+	return func(db *DB) error {
+		db.txCallbackErr = fn
+		return nil
+	}
+}
+
 // DBUseReflectValuer 使用基于reflect的方法
 func DBUseReflectValuer() DBOption {
 	return func(db *DB) error {
@@ -106,21 +144,35 @@ func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{tx: tx, db: db}, nil
+	return &Tx{tx: tx, db: db, ctx: ctx}, nil
 }
 
 func (db *DB) DoTx(ctx context.Context,
 	fn func(ctx context.Context, tx *Tx) error,
-	opts *sql.TxOptions) (err error) { // err 是保留最新的错误
+	opts *sql.TxOptions) error {
+	if db.retry == nil {
+		_, err := db.doTx(ctx, fn, opts)
+		return err
+	}
+	return db.retry.do(ctx, func(ctx context.Context) (bool, error) {
+		return db.doTx(ctx, fn, opts)
+	})
+}
+
+// doTx 执行一次事务，retryable 代表 fn 或者提交返回的错误是否可以通过重新执行事务解决
+func (db *DB) doTx(ctx context.Context,
+	fn func(ctx context.Context, tx *Tx) error,
+	opts *sql.TxOptions) (retryable bool, err error) { // err 是保留最新的错误
 	tx, err := db.BeginTx(ctx, opts)
 	if err != nil {
-		return err
+		return db.dialect.isRetryable(err), err
 	}
 	defer func() {
 		if e := recover(); e != nil || err != nil {
 			if e != nil {
 				err = errs.NewErrTxFuncFailed(e)
 			}
+			retryable = db.dialect.isRetryable(err)
 			rE := tx.Rollback()
 			if rE != nil {
 				err = errs.NewErrTxRollbackFailed(rE)
@@ -128,14 +180,27 @@ func (db *DB) DoTx(ctx context.Context,
 		} else {
 			err = tx.Commit()
 			if err != nil {
+				retryable = db.dialect.isRetryable(err)
 				err = errs.NewErrTxCommitFailed(err)
 			}
 		}
 	}()
 	err = fn(ctx, tx)
-	return err
+	return
 }
 
+// Close 关闭缓存的预编译语句、主库以及所有的只读副本
 func (db *DB) Close() error {
-	return db.db.Close()
+	if db.stmts != nil {
+		_ = db.stmts.close()
+	}
+	err := db.db.Close()
+	if db.replicas != nil {
+		for _, r := range db.replicas.replicas {
+			if e := r.db.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
 }
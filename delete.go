@@ -1,15 +1,18 @@
 package morm
 
 import (
+	"context"
 	"github.com/NotFound1911/morm/errors"
 )
 
 type Deleter[T any] struct {
 	builder
+	ctes []CTE
 	sess session
 }
 
 func (d *Deleter[T]) Build() (*Query, error) {
+	d.reset()
 	var (
 		t   T
 		err error
@@ -18,6 +21,9 @@ func (d *Deleter[T]) Build() (*Query, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = d.buildWith(d.ctes); err != nil {
+		return nil, err
+	}
 	d.sqlBuilder.WriteString("DELETE FROM ")
 	if err = d.buildTable(d.table); err != nil {
 		return nil, err
@@ -38,19 +44,25 @@ func (d *Deleter[T]) Build() (*Query, error) {
 func (d *Deleter[T]) buildTable(table TableReference) error {
 	switch tab := table.(type) {
 	case nil:
-		d.quote(d.model.TableName)
+		d.quote(d.tableName(d.model))
 	case Table:
 		model, err := d.r.Get(tab.entity)
 		if err != nil {
 			return err
 		}
-		d.quote(model.TableName)
+		d.quote(d.tableName(model))
 	default:
 		return errs.NewErrUnsupportedExpressionType(tab)
 	}
 	return nil
 }
 
+// With 在删除语句前面加上 WITH 子句，CTE 可以在 WHERE 的子查询中使用
+func (d *Deleter[T]) With(ctes ...CTE) *Deleter[T] {
+	d.ctes = ctes
+	return d
+}
+
 // From accepts model definition
 func (d *Deleter[T]) From(table TableReference) *Deleter[T] {
 	d.table = table
@@ -62,6 +74,13 @@ func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
 	d.where = ps
 	return d
 }
+func (d *Deleter[T]) Exec(ctx context.Context) Result {
+	if sd, ok := d.sess.(*ShardingDB); ok {
+		return shardingDelete[T](ctx, sd, d)
+	}
+	return exec(ctx, d.sess, d.core, &QueryContext{Builder: d, Type: "DELETE"})
+}
+
 func NewDeleter[T any](sess session) *Deleter[T] {
 	c := sess.getCore()
 	return &Deleter[T]{
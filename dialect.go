@@ -1,7 +1,10 @@
 package morm
 
 import (
+	"errors"
 	"github.com/NotFound1911/morm/errors"
+	"github.com/go-sql-driver/mysql"
+	"strings"
 )
 
 var (
@@ -12,6 +15,36 @@ var (
 type Dialect interface {
 	quoter() byte
 	buildUpsert(b *builder, odk *Upsert) error
+	// supportReturning 是否支持 INSERT ... RETURNING
+	supportReturning() bool
+	// maxParams 单条语句最多允许的占位符数量
+	maxParams() int
+	// name 方言名称，用于错误信息
+	name() string
+	// buildSetOperation 构造 UNION、INTERSECT 这一类集合操作符
+	buildSetOperation(b *builder, op setOpt) error
+	// wrapSetOperand 集合操作的每个查询是否需要用括号括起来
+	wrapSetOperand() bool
+	// buildNullsOrder 构造 NULLS FIRST 或者 NULLS LAST
+	buildNullsOrder(b *builder, nullsFirst bool) error
+	// buildRollup 构造 GROUP BY 的 WITH ROLLUP
+	buildRollup(b *builder) error
+	// buildGroupConcat 构造拼接分组内字符串的聚合函数
+	buildGroupConcat(b *builder, a Aggregate) error
+	// buildLock 构造 FOR UPDATE 这一类锁定读
+	buildLock(b *builder, l *lock) error
+	// buildMatch 构造全文检索
+	buildMatch(b *builder, m MatchExpr) error
+	// buildJSONExtract 构造 JSON 取值
+	buildJSONExtract(b *builder, j JSONExpr) error
+	// buildLimitOffset 构造 LIMIT 和 OFFSET，小于等于 0 代表没有设置
+	buildLimitOffset(b *builder, limit int, offset int) error
+	// buildSavepoint 构造创建、回滚或者释放保存点的语句
+	buildSavepoint(b *builder, op savepointOpt, name string) error
+	// isRetryable 驱动返回的错误是否可以通过重新执行整个事务解决，例如死锁
+	isRetryable(err error) bool
+	// buildLiteral 输出字符串字面量，需要按照数据库的规则转义
+	buildLiteral(b *builder, val string) error
 }
 
 type standardSQL struct {
@@ -27,6 +60,125 @@ func (s standardSQL) buildUpsert(b *builder, odk *Upsert) error {
 	panic("implement me")
 }
 
+func (s standardSQL) supportReturning() bool {
+	return false
+}
+
+func (s standardSQL) maxParams() int {
+	return 999
+}
+
+func (s standardSQL) name() string {
+	return "SQL"
+}
+
+func (s standardSQL) buildSetOperation(b *builder, op setOpt) error {
+	b.sqlBuilder.WriteString(op.String())
+	return nil
+}
+
+func (s standardSQL) wrapSetOperand() bool {
+	return true
+}
+
+func (s standardSQL) buildNullsOrder(b *builder, nullsFirst bool) error {
+	if nullsFirst {
+		b.sqlBuilder.WriteString(" NULLS FIRST")
+	} else {
+		b.sqlBuilder.WriteString(" NULLS LAST")
+	}
+	return nil
+}
+
+func (s standardSQL) buildRollup(b *builder) error {
+	return errs.NewErrUnsupportedByDialect(s.name(), "WITH ROLLUP")
+}
+
+// buildLimitOffset 标准 SQL 允许单独使用 OFFSET
+func (s standardSQL) buildLimitOffset(b *builder, limit int, offset int) error {
+	if limit > 0 {
+		b.sqlBuilder.WriteString(" LIMIT ?")
+		b.addArgs(limit)
+	}
+	if offset > 0 {
+		b.sqlBuilder.WriteString(" OFFSET ?")
+		b.addArgs(offset)
+	}
+	return nil
+}
+
+// buildSavepoint SAVEPOINT name、ROLLBACK TO SAVEPOINT name 或者 RELEASE SAVEPOINT name
+func (s standardSQL) buildSavepoint(b *builder, op savepointOpt, name string) error {
+	b.sqlBuilder.WriteString(string(op))
+	b.sqlBuilder.WriteByte(' ')
+	b.quote(name)
+	return nil
+}
+
+// isRetryable 通过 SQLSTATE 判断，40001 是序列化失败，40P01 是 PostgreSQL 的死锁
+// pgx 和 lib/pq 的错误都有 SQLState 方法
+func (s standardSQL) isRetryable(err error) bool {
+	var se interface{ SQLState() string }
+	if !errors.As(err, &se) {
+		return false
+	}
+	state := se.SQLState()
+	return state == "40001" || state == "40P01"
+}
+
+// buildLiteral 标准 SQL 只需要把单引号写两次，不允许包含 NUL
+func (s standardSQL) buildLiteral(b *builder, val string) error {
+	if strings.IndexByte(val, 0) >= 0 {
+		return errs.NewErrInvalidLiteral(val)
+	}
+	b.sqlBuilder.WriteByte('\'')
+	b.sqlBuilder.WriteString(strings.ReplaceAll(val, "'", "''"))
+	b.sqlBuilder.WriteByte('\'')
+	return nil
+}
+
+func (s standardSQL) buildLock(b *builder, l *lock) error {
+	b.sqlBuilder.WriteByte(' ')
+	b.sqlBuilder.WriteString(l.mode)
+	if l.wait != "" {
+		b.sqlBuilder.WriteByte(' ')
+		b.sqlBuilder.WriteString(l.wait)
+	}
+	return nil
+}
+
+func (s standardSQL) buildMatch(b *builder, m MatchExpr) error {
+	return errs.NewErrUnsupportedByDialect(s.name(), "MATCH AGAINST")
+}
+
+// buildJSONExtract JSON_VALUE(expr, ?)
+func (s standardSQL) buildJSONExtract(b *builder, j JSONExpr) error {
+	b.sqlBuilder.WriteString("JSON_VALUE(")
+	if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+		return err
+	}
+	b.sqlBuilder.WriteString(",?)")
+	b.addArgs(j.path)
+	return nil
+}
+
+// buildGroupConcat STRING_AGG([DISTINCT ]expr, 'sep')
+func (s standardSQL) buildGroupConcat(b *builder, a Aggregate) error {
+	b.sqlBuilder.WriteString("STRING_AGG(")
+	if a.distinct {
+		b.sqlBuilder.WriteString("DISTINCT ")
+	}
+	if err := b.buildAggregateArg(a); err != nil {
+		return err
+	}
+	b.sqlBuilder.WriteByte(',')
+	if err := b.literal(a.separator); err != nil {
+		return err
+	}
+	b.sqlBuilder.WriteByte(')')
+	return nil
+}
+
 type mysqlDialect struct {
 	standardSQL
 }
@@ -34,6 +186,121 @@ type mysqlDialect struct {
 func (m *mysqlDialect) quoter() byte {
 	return '`'
 }
+
+// supportReturning MySQL 不支持 RETURNING，自增主键通过 LastInsertId 获得
+func (m *mysqlDialect) supportReturning() bool {
+	return false
+}
+
+// maxParams MySQL 预编译语句的占位符不能超过 65535 个
+func (m *mysqlDialect) maxParams() int {
+	return 65535
+}
+
+func (m *mysqlDialect) name() string {
+	return "MySQL"
+}
+
+// buildNullsOrder MySQL 不支持 NULLS FIRST 和 NULLS LAST
+func (m *mysqlDialect) buildNullsOrder(b *builder, nullsFirst bool) error {
+	if nullsFirst {
+		return errs.NewErrUnsupportedByDialect(m.name(), "NULLS FIRST")
+	}
+	return errs.NewErrUnsupportedByDialect(m.name(), "NULLS LAST")
+}
+
+// buildLimitOffset MySQL 的 OFFSET 必须跟在 LIMIT 后面
+func (m *mysqlDialect) buildLimitOffset(b *builder, limit int, offset int) error {
+	if offset > 0 && limit <= 0 {
+		return errs.NewErrOffsetWithoutLimit(m.name())
+	}
+	return m.standardSQL.buildLimitOffset(b, limit, offset)
+}
+
+// isRetryable 1213 是死锁，1205 是等待锁超时
+func (m *mysqlDialect) isRetryable(err error) bool {
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return false
+	}
+	return me.Number == 1213 || me.Number == 1205
+}
+
+// buildLiteral MySQL 默认没有开启 NO_BACKSLASH_ESCAPES，\ 也是转义字符，所以需要转义为 \\
+// 开启了 NO_BACKSLASH_ESCAPES 的时候，\\ 会变成两个 \，值不对但是不会有注入的问题
+func (m *mysqlDialect) buildLiteral(b *builder, val string) error {
+	return m.standardSQL.buildLiteral(b, strings.ReplaceAll(val, "\\", "\\\\"))
+}
+
+func (m *mysqlDialect) buildRollup(b *builder) error {
+	b.sqlBuilder.WriteString(" WITH ROLLUP")
+	return nil
+}
+
+// buildGroupConcat GROUP_CONCAT([DISTINCT ]expr SEPARATOR 'sep')
+// SEPARATOR 只能是字面量，不能使用占位符
+func (m *mysqlDialect) buildGroupConcat(b *builder, a Aggregate) error {
+	b.sqlBuilder.WriteString("GROUP_CONCAT(")
+	if a.distinct {
+		b.sqlBuilder.WriteString("DISTINCT ")
+	}
+	if err := b.buildAggregateArg(a); err != nil {
+		return err
+	}
+	b.sqlBuilder.WriteString(" SEPARATOR ")
+	if err := b.literal(a.separator); err != nil {
+		return err
+	}
+	b.sqlBuilder.WriteByte(')')
+	return nil
+}
+
+// buildMatch MATCH(col1,col2) AGAINST(? IN BOOLEAN MODE)
+func (m *mysqlDialect) buildMatch(b *builder, me MatchExpr) error {
+	b.sqlBuilder.WriteString("MATCH(")
+	for i, col := range me.cols {
+		if i > 0 {
+			b.sqlBuilder.WriteByte(',')
+		}
+		if err := b.buildColumn(col.table, col.name); err != nil {
+			return err
+		}
+	}
+	b.sqlBuilder.WriteString(") AGAINST(? IN BOOLEAN MODE)")
+	b.addArgs(me.query)
+	return nil
+}
+
+// buildJSONExtract JSON_EXTRACT(`col`,?) 或者 `col`->>'path'
+// ->> 的 path 只能是字面量，所以必须先校验 path
+func (m *mysqlDialect) buildJSONExtract(b *builder, j JSONExpr) error {
+	if j.unquote {
+		if !validJSONPath(j.path) {
+			return errs.NewErrInvalidJSONPath(j.path)
+		}
+		if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+			return err
+		}
+		b.sqlBuilder.WriteString("->>")
+		return b.literal(j.path)
+	}
+	b.sqlBuilder.WriteString("JSON_EXTRACT(")
+	if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+		return err
+	}
+	b.sqlBuilder.WriteString(",?)")
+	b.addArgs(j.path)
+	return nil
+}
+
+// buildSetOperation MySQL 8.0.31 之前不支持 INTERSECT 和 EXCEPT
+func (m *mysqlDialect) buildSetOperation(b *builder, op setOpt) error {
+	if op == setOptIntersect || op == setOptExcept {
+		return errs.NewErrUnsupportedByDialect(m.name(), op.String())
+	}
+	b.sqlBuilder.WriteString(op.String())
+	return nil
+}
 func (m *mysqlDialect) buildUpsert(b *builder, odk *Upsert) error {
 	b.sqlBuilder.WriteString(" ON DUPLICATE KEY UPDATE ")
 	for i, a := range odk.assigns {
@@ -55,8 +322,10 @@ func (m *mysqlDialect) buildUpsert(b *builder, odk *Upsert) error {
 			if err != nil {
 				return err
 			}
-			b.sqlBuilder.WriteString("=?")
-			b.addArgs(assign.val)
+			b.sqlBuilder.WriteByte('=')
+			if err = b.buildExpression(assign.val); err != nil {
+				return err
+			}
 		default:
 			return errs.NewErrUnsupportedAssignableType(a)
 		}
@@ -71,6 +340,88 @@ type sqlite3Dialect struct {
 func (s *sqlite3Dialect) quoter() byte {
 	return '`'
 }
+
+// supportReturning SQLite 3.35.0 开始支持 RETURNING
+func (s *sqlite3Dialect) supportReturning() bool {
+	return true
+}
+
+// maxParams SQLITE_MAX_VARIABLE_NUMBER 在 3.32.0 之前是 999，之后是 32766
+func (s *sqlite3Dialect) maxParams() int {
+	return 32766
+}
+
+func (s *sqlite3Dialect) name() string {
+	return "SQLite"
+}
+
+// buildGroupConcat GROUP_CONCAT(expr, 'sep')
+// SQLite 的 DISTINCT 聚合只能有一个参数，所以只能使用默认的分隔符 ,
+func (s *sqlite3Dialect) buildGroupConcat(b *builder, a Aggregate) error {
+	b.sqlBuilder.WriteString("GROUP_CONCAT(")
+	if a.distinct {
+		if a.separator != "," {
+			return errs.NewErrUnsupportedByDialect(s.name(), "GROUP_CONCAT(DISTINCT) 自定义分隔符")
+		}
+		b.sqlBuilder.WriteString("DISTINCT ")
+	}
+	if err := b.buildAggregateArg(a); err != nil {
+		return err
+	}
+	if !a.distinct {
+		b.sqlBuilder.WriteByte(',')
+		if err := b.literal(a.separator); err != nil {
+			return err
+		}
+	}
+	b.sqlBuilder.WriteByte(')')
+	return nil
+}
+
+// buildLock SQLite 的事务锁住的是整个数据库，不支持行锁，所以忽略
+func (s *sqlite3Dialect) buildLock(b *builder, l *lock) error {
+	return nil
+}
+
+// buildMatch SQLite 的全文检索依赖 FTS 虚拟表，语法完全不同
+func (s *sqlite3Dialect) buildMatch(b *builder, m MatchExpr) error {
+	return errs.NewErrUnsupportedByDialect(s.name(), "MATCH AGAINST")
+}
+
+// buildJSONExtract json_extract(`col`,?)
+// SQLite 的 json_extract 对于字符串返回的就是去掉引号的文本，所以不需要区分 unquote
+func (s *sqlite3Dialect) buildJSONExtract(b *builder, j JSONExpr) error {
+	b.sqlBuilder.WriteString("json_extract(")
+	if err := b.buildColumn(j.col.table, j.col.name); err != nil {
+		return err
+	}
+	b.sqlBuilder.WriteString(",?)")
+	b.addArgs(j.path)
+	return nil
+}
+
+// buildLimitOffset SQLite 的 OFFSET 必须跟在 LIMIT 后面
+func (s *sqlite3Dialect) buildLimitOffset(b *builder, limit int, offset int) error {
+	if offset > 0 && limit <= 0 {
+		return errs.NewErrOffsetWithoutLimit(s.name())
+	}
+	return s.standardSQL.buildLimitOffset(b, limit, offset)
+}
+
+// isRetryable SQLITE_BUSY 代表数据库被其它连接锁住了
+// 引入 go-sqlite3 需要 cgo，所以通过错误信息判断，go-sqlite3 和 modernc.org/sqlite 的错误信息都包含 database is locked
+func (s *sqlite3Dialect) isRetryable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+func (s *sqlite3Dialect) buildRollup(b *builder) error {
+	return errs.NewErrUnsupportedByDialect(s.name(), "WITH ROLLUP")
+}
+
+// wrapSetOperand SQLite 不允许用括号括起复合查询中的 SELECT
+func (s *sqlite3Dialect) wrapSetOperand() bool {
+	return false
+}
 func (s *sqlite3Dialect) buildUpsert(b *builder, odk *Upsert) error {
 	b.sqlBuilder.WriteString(" ON CONFLICT")
 	if len(odk.conflictColumns) > 0 {
@@ -105,8 +456,10 @@ func (s *sqlite3Dialect) buildUpsert(b *builder, odk *Upsert) error {
 			if err != nil {
 				return err
 			}
-			b.sqlBuilder.WriteString("=?")
-			b.addArgs(assign.val)
+			b.sqlBuilder.WriteByte('=')
+			if err = b.buildExpression(assign.val); err != nil {
+				return err
+			}
 		default:
 			return errs.NewErrUnsupportedAssignableType(a)
 		}
@@ -38,4 +38,85 @@ const (
 
 	// ErrNoUpdatedColumns 没有更新的列
 	ErrNoUpdatedColumns
+
+	// ErrMultiplePrimaryKeys 设置了多个主键
+	ErrMultiplePrimaryKeys
+
+	// ErrInvalidFieldValue 字段值类型不匹配
+	ErrInvalidFieldValue
+
+	// ErrMissingPrimaryKey 模型没有主键
+	ErrMissingPrimaryKey
+
+	// ErrUpdateZeroRow 没有要更新的数据
+	ErrUpdateZeroRow
+
+	// ErrUnsupportedByDialect 方言不支持该特性
+	ErrUnsupportedByDialect
+
+	// ErrLockOutsideTx 在事务之外使用锁定读
+	ErrLockOutsideTx
+
+	// ErrEmptyInValues IN 后面没有任何值
+	ErrEmptyInValues
+
+	// ErrInvalidQueryParam 查询参数格式错误
+	ErrInvalidQueryParam
+
+	// ErrFieldNotAllowed 字段不允许用于过滤或者排序
+	ErrFieldNotAllowed
+
+	// ErrOffsetWithoutLimit 只有 OFFSET 没有 LIMIT
+	ErrOffsetWithoutLimit
+
+	// ErrClauseRequires 子句依赖的另外一个子句没有设置
+	ErrClauseRequires
+
+	// ErrMissingShardingRule 模型没有配置分片规则
+	ErrMissingShardingRule
+
+	// ErrMissingShardingKey 查询条件中没有分片键，并且不允许广播
+	ErrMissingShardingKey
+
+	// ErrUnknownShardingDB 分片算法返回了不存在的库
+	ErrUnknownShardingDB
+
+	// ErrInvalidShardingValue 分片键的值无法用于分片
+	ErrInvalidShardingValue
+
+	// ErrShardingNotSupported 分库分表不支持的操作
+	ErrShardingNotSupported
+
+	// ErrInvalidSavepoint 保存点的名字不合法
+	ErrInvalidSavepoint
+
+	// ErrTxPropagation 违反了事务传播行为的要求
+	ErrTxPropagation
+
+	// ErrTxRetryFailed 事务重试之后仍然失败
+	ErrTxRetryFailed
+
+	// ErrTxCallbackPanic 事务的回调 panic
+	ErrTxCallbackPanic
+
+	// ErrParamCount 预编译查询的参数数量不对
+	ErrParamCount
+
+	// ErrInvalidJSONPath JSON path 不合法
+	ErrInvalidJSONPath
+
+	// ErrInvalidLiteral 字符串字面量中包含不允许的字符
+	ErrInvalidLiteral
+
+	// ErrCrossShardWrite 写操作涉及多个分片，但是没有开启跨分片写
+	ErrCrossShardWrite
+
+	// ErrShardingPartialWrite 跨分片写的时候部分分片已经写入
+	ErrShardingPartialWrite
+
+	// ErrUpdatePrimaryKey 不允许更新主键
+	ErrUpdatePrimaryKey
+
+	// ErrParamInValues IN 的值中使用了 Param
+	ErrParamInValues
 )
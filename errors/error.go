@@ -1,8 +1,10 @@
 package errs
 
 import (
+	"errors"
 	"fmt"
 	"github.com/NotFound1911/morm/errors/code"
+	"strings"
 )
 
 type withCode struct {
@@ -19,6 +21,9 @@ func WithCode(code int, format string, args ...interface{}) error {
 
 func (w *withCode) Error() string { return fmt.Sprintf("%+v", w.err) }
 
+// Unwrap 使用 %w 构造的错误可以通过 errors.Is 和 errors.As 找到原本的错误
+func (w *withCode) Unwrap() error { return errors.Unwrap(w.err) }
+
 func NewErrUnknown(exp any) error {
 	return WithCode(code.ErrUnknown, fmt.Sprintf("morm 未知错误:%+v", exp))
 }
@@ -63,3 +68,111 @@ func NewErrTxCommitFailed(exp any) error {
 func NewErrNoUpdatedColumns() error {
 	return WithCode(code.ErrNoUpdatedColumns, fmt.Sprintf("morm 没有更新的列"))
 }
+
+func NewErrMultiplePrimaryKeys(first, second string) error {
+	return WithCode(code.ErrMultiplePrimaryKeys, fmt.Sprintf("morm 只支持一个主键, 同时设置了 %s 和 %s", first, second))
+}
+
+func NewErrInvalidFieldValue(field string, val any) error {
+	return WithCode(code.ErrInvalidFieldValue, fmt.Sprintf("morm 字段 %s 无法设置为 %+v", field, val))
+}
+
+func NewErrMissingPrimaryKey(exp any) error {
+	return WithCode(code.ErrMissingPrimaryKey, fmt.Sprintf("morm 模型没有主键:%+v", exp))
+}
+
+func NewErrUpdateZeroRow() error {
+	return WithCode(code.ErrUpdateZeroRow, fmt.Sprintf("morm 没有要更新的数据"))
+}
+
+func NewErrUnsupportedByDialect(dialect string, feature string) error {
+	return WithCode(code.ErrUnsupportedByDialect, fmt.Sprintf("morm %s 不支持 %s", dialect, feature))
+}
+
+func NewErrLockOutsideTx(mode string) error {
+	return WithCode(code.ErrLockOutsideTx, fmt.Sprintf("morm %s 只能在事务中使用", mode))
+}
+
+func NewErrEmptyInValues(field string) error {
+	return WithCode(code.ErrEmptyInValues, fmt.Sprintf("morm %s IN 后面没有任何值", field))
+}
+
+func NewErrInvalidQueryParam(param string, reason string) error {
+	return WithCode(code.ErrInvalidQueryParam, fmt.Sprintf("morm 查询参数 %s 错误: %s", param, reason))
+}
+
+func NewErrFieldNotAllowed(field string) error {
+	return WithCode(code.ErrFieldNotAllowed, fmt.Sprintf("morm 字段 %s 不允许使用", field))
+}
+
+func NewErrOffsetWithoutLimit(dialect string) error {
+	return WithCode(code.ErrOffsetWithoutLimit, fmt.Sprintf("morm %s 不支持只有 OFFSET 没有 LIMIT", dialect))
+}
+
+func NewErrClauseRequires(clause string, required string) error {
+	return WithCode(code.ErrClauseRequires, fmt.Sprintf("morm %s 必须和 %s 一起使用", clause, required))
+}
+
+func NewErrMissingShardingRule(table string) error {
+	return WithCode(code.ErrMissingShardingRule, fmt.Sprintf("morm 表 %s 没有配置分片规则", table))
+}
+
+func NewErrMissingShardingKey(key string) error {
+	return WithCode(code.ErrMissingShardingKey, fmt.Sprintf("morm 查询条件中没有分片键 %s", key))
+}
+
+func NewErrUnknownShardingDB(db string) error {
+	return WithCode(code.ErrUnknownShardingDB, fmt.Sprintf("morm 未知的分库 %s", db))
+}
+
+func NewErrInvalidShardingValue(val any) error {
+	return WithCode(code.ErrInvalidShardingValue, fmt.Sprintf("morm 分片键的值 %+v 无法用于分片", val))
+}
+
+func NewErrShardingNotSupported(feature string) error {
+	return WithCode(code.ErrShardingNotSupported, fmt.Sprintf("morm 分库分表不支持 %s", feature))
+}
+
+func NewErrInvalidSavepoint(name string) error {
+	return WithCode(code.ErrInvalidSavepoint, fmt.Sprintf("morm 保存点的名字 %s 不合法", name))
+}
+
+func NewErrTxPropagation(propagation string, reason string) error {
+	return WithCode(code.ErrTxPropagation, fmt.Sprintf("morm 事务传播行为 %s 要求不满足: %s", propagation, reason))
+}
+
+func NewErrTxRetryFailed(attempts int, err error) error {
+	return WithCode(code.ErrTxRetryFailed, "morm 事务执行了 %d 次仍然失败: %w", attempts, err)
+}
+
+func NewErrTxCallbackPanic(exp any) error {
+	return WithCode(code.ErrTxCallbackPanic, fmt.Sprintf("morm 事务回调 panic:%+v", exp))
+}
+
+func NewErrParamCount(want int, got int) error {
+	return WithCode(code.ErrParamCount, fmt.Sprintf("morm 预编译查询需要 %d 个参数，传入了 %d 个", want, got))
+}
+
+func NewErrInvalidJSONPath(path string) error {
+	return WithCode(code.ErrInvalidJSONPath, fmt.Sprintf("morm 不合法的 JSON path %s", path))
+}
+
+func NewErrInvalidLiteral(val string) error {
+	return WithCode(code.ErrInvalidLiteral, fmt.Sprintf("morm 字符串字面量 %q 中包含不允许的字符", val))
+}
+
+func NewErrCrossShardWrite(cnt int) error {
+	return WithCode(code.ErrCrossShardWrite, fmt.Sprintf("morm 写操作涉及 %d 个分片，需要开启 ShardingWithCrossShardWrite", cnt))
+}
+
+func NewErrShardingPartialWrite(done []string, err error) error {
+	return WithCode(code.ErrShardingPartialWrite, "morm 分片 %s 已经写入，之后的写入失败: %w", strings.Join(done, ","), err)
+}
+
+func NewErrUpdatePrimaryKey(col string) error {
+	return WithCode(code.ErrUpdatePrimaryKey, fmt.Sprintf("morm 不能更新主键 %s", col))
+}
+
+func NewErrParamInValues(field string) error {
+	return WithCode(code.ErrParamInValues, fmt.Sprintf("morm 字段 %s 的 IN 中不能使用 Param，Param 只能代表一个值", field))
+}
@@ -92,3 +92,177 @@ func Some(sub Subquery) SubqueryExpr {
 		pred: "SOME",
 	}
 }
+
+var _ Selectable = CaseExpr{}
+var _ Expression = CaseExpr{}
+
+// CaseExpr 对应 CASE WHEN ... THEN ... ELSE ... END
+type CaseExpr struct {
+	whens []caseWhen
+	els   Expression
+	alias string
+}
+
+type caseWhen struct {
+	cond Predicate
+	val  Expression
+}
+
+// Case 例如 Case().When(C("Status").EQ(1), "active").Else("inactive")
+func Case() CaseExpr {
+	return CaseExpr{}
+}
+
+func (c CaseExpr) When(p Predicate, val any) CaseExpr {
+	whens := make([]caseWhen, len(c.whens), len(c.whens)+1)
+	copy(whens, c.whens)
+	c.whens = append(whens, caseWhen{cond: p, val: exprOf(val)})
+	return c
+}
+
+func (c CaseExpr) Else(val any) CaseExpr {
+	c.els = exprOf(val)
+	return c
+}
+
+func (c CaseExpr) As(alias string) CaseExpr {
+	c.alias = alias
+	return c
+}
+
+func (c CaseExpr) fieldName() string {
+	return ""
+}
+
+func (c CaseExpr) target() TableReference {
+	return nil
+}
+
+func (c CaseExpr) selectedAlias() string {
+	return c.alias
+}
+
+func (CaseExpr) expr() {}
+
+func (c CaseExpr) EQ(arg any) Predicate { // = 等于
+	return Predicate{
+		left:  c,
+		opt:   optEQ,
+		right: exprOf(arg),
+	}
+}
+func (c CaseExpr) LT(arg any) Predicate { // < 小于
+	return Predicate{
+		left:  c,
+		opt:   optLT,
+		right: exprOf(arg),
+	}
+}
+func (c CaseExpr) GT(arg any) Predicate { // > 大于
+	return Predicate{
+		left:  c,
+		opt:   optGT,
+		right: exprOf(arg),
+	}
+}
+
+var _ Selectable = FuncExpr{}
+var _ Expression = FuncExpr{}
+
+// FuncExpr SQL 函数调用，例如 COALESCE, LOWER, DATE, CONCAT
+type FuncExpr struct {
+	fn    string
+	args  []Expression
+	alias string
+}
+
+// Func 调用 SQL 函数，Column 类型的参数会映射为列名，其余的参数作为占位符参数
+func Func(fn string, args ...any) FuncExpr {
+	exprs := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		exprs = append(exprs, exprOf(arg))
+	}
+	return FuncExpr{
+		fn:   fn,
+		args: exprs,
+	}
+}
+
+func (f FuncExpr) As(alias string) FuncExpr {
+	f.alias = alias
+	return f
+}
+
+func (f FuncExpr) fieldName() string {
+	return ""
+}
+
+func (f FuncExpr) target() TableReference {
+	return nil
+}
+
+func (f FuncExpr) selectedAlias() string {
+	return f.alias
+}
+
+func (FuncExpr) expr() {}
+
+func (f FuncExpr) EQ(arg any) Predicate { // = 等于
+	return Predicate{
+		left:  f,
+		opt:   optEQ,
+		right: exprOf(arg),
+	}
+}
+func (f FuncExpr) LT(arg any) Predicate { // < 小于
+	return Predicate{
+		left:  f,
+		opt:   optLT,
+		right: exprOf(arg),
+	}
+}
+func (f FuncExpr) GT(arg any) Predicate { // > 大于
+	return Predicate{
+		left:  f,
+		opt:   optGT,
+		right: exprOf(arg),
+	}
+}
+
+var _ Expression = AliasExpr{}
+
+// AliasExpr 引用 SELECT 中定义的别名，可以用于 ORDER BY 和 HAVING
+type AliasExpr struct {
+	alias string
+}
+
+// Alias 例如 NewSelector[T](db).Select(Avg("Age").As("avg_age")).OrderBy(DescExpr(Alias("avg_age")))
+func Alias(alias string) AliasExpr {
+	return AliasExpr{
+		alias: alias,
+	}
+}
+
+func (AliasExpr) expr() {}
+
+func (a AliasExpr) EQ(arg any) Predicate { // = 等于
+	return Predicate{
+		left:  a,
+		opt:   optEQ,
+		right: exprOf(arg),
+	}
+}
+func (a AliasExpr) LT(arg any) Predicate { // < 小于
+	return Predicate{
+		left:  a,
+		opt:   optLT,
+		right: exprOf(arg),
+	}
+}
+func (a AliasExpr) GT(arg any) Predicate { // > 大于
+	return Predicate{
+		left:  a,
+		opt:   optGT,
+		right: exprOf(arg),
+	}
+}
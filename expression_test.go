@@ -0,0 +1,156 @@
+package morm
+
+import (
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCaseExpr_Build(t *testing.T) {
+	db := memoryDB(t)
+	status := Case().When(C("Age").LT(18), "child").
+		When(C("Age").LT(60), "adult").Else("elder")
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select",
+			q:    NewSelector[TestModel](db).Select(C("Id"), status.As("status")),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,CASE WHEN `age` < ? THEN ? WHEN `age` < ? THEN ? ELSE ? END AS `status` FROM `test_model`;",
+				Args: []any{18, "child", 60, "adult", "elder"},
+			},
+		},
+		{
+			name: "without else",
+			q:    NewSelector[TestModel](db).Select(Case().When(C("Age").EQ(1), C("FirstName"))),
+			wantQuery: &Query{
+				SQL:  "SELECT CASE WHEN `age` = ? THEN `first_name` END FROM `test_model`;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "where",
+			q:    NewSelector[TestModel](db).Where(status.EQ("adult")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE CASE WHEN `age` < ? THEN ? WHEN `age` < ? THEN ? ELSE ? END = ?;",
+				Args: []any{18, "child", 60, "adult", "elder", "adult"},
+			},
+		},
+		{
+			name: "group by",
+			q:    NewSelector[TestModel](db).Select(status.As("status"), Count("Id")).GroupBy(status),
+			wantQuery: &Query{
+				SQL: "SELECT CASE WHEN `age` < ? THEN ? WHEN `age` < ? THEN ? ELSE ? END AS `status`,COUNT(`id`) FROM `test_model` " +
+					"GROUP BY CASE WHEN `age` < ? THEN ? WHEN `age` < ? THEN ? ELSE ? END;",
+				Args: []any{18, "child", 60, "adult", "elder", 18, "child", 60, "adult", "elder"},
+			},
+		},
+		{
+			name: "order by",
+			q:    NewSelector[TestModel](db).OrderBy(DescExpr(status)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` ORDER BY CASE WHEN `age` < ? THEN ? WHEN `age` < ? THEN ? ELSE ? END DESC;",
+				Args: []any{18, "child", 60, "adult", "elder"},
+			},
+		},
+		{
+			name: "assign",
+			q: NewUpdater[TestModel](db).Set(Assign("FirstName",
+				Case().When(C("Age").GT(18), "adult").Else(C("FirstName")))),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=CASE WHEN `age` > ? THEN ? ELSE `first_name` END;",
+				Args: []any{18, "adult"},
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Select(Case().When(C("Invalid").EQ(1), 1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestFuncExpr_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select",
+			q:    NewSelector[TestModel](db).Select(Func("COALESCE", C("LastName"), "").As("last_name")),
+			wantQuery: &Query{
+				SQL:  "SELECT COALESCE(`last_name`,?) AS `last_name` FROM `test_model`;",
+				Args: []any{""},
+			},
+		},
+		{
+			name: "no args",
+			q:    NewSelector[TestModel](db).Select(Func("NOW")),
+			wantQuery: &Query{
+				SQL: "SELECT NOW() FROM `test_model`;",
+			},
+		},
+		{
+			name: "nested",
+			q:    NewSelector[TestModel](db).Where(Func("LOWER", Func("CONCAT", C("FirstName"), C("LastName"))).EQ("tomcat")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE LOWER(CONCAT(`first_name`,`last_name`)) = ?;",
+				Args: []any{"tomcat"},
+			},
+		},
+		{
+			name: "group by",
+			q: NewSelector[TestModel](db).Select(Func("LOWER", C("FirstName")).As("name"), Count("Id")).
+				GroupBy(Func("LOWER", C("FirstName"))),
+			wantQuery: &Query{
+				SQL: "SELECT LOWER(`first_name`) AS `name`,COUNT(`id`) FROM `test_model` GROUP BY LOWER(`first_name`);",
+			},
+		},
+		{
+			name: "order by",
+			q:    NewSelector[TestModel](db).OrderBy(AscExpr(Func("LOWER", C("FirstName")))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY LOWER(`first_name`) ASC;",
+			},
+		},
+		{
+			name: "assign",
+			q:    NewUpdater[TestModel](db).Set(Assign("FirstName", Func("UPPER", C("FirstName")))),
+			wantQuery: &Query{
+				SQL: "UPDATE `test_model` SET `first_name`=UPPER(`first_name`);",
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Select(Func("LOWER", C("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package morm
+
+var _ Selectable = MatchExpr{}
+var _ Expression = MatchExpr{}
+
+// MatchExpr 全文检索，对应 MySQL 的 MATCH(col1,col2) AGAINST(? IN BOOLEAN MODE)
+// 可以作为查询条件，也可以把相关度作为列查询出来或者用于排序
+type MatchExpr struct {
+	cols  []Column
+	query string
+	alias string
+}
+
+// Match 指定全文索引覆盖的列，例如 Match(C("Title"), C("Content")).Against("+golang -java")
+func Match(cols ...Column) MatchExpr {
+	return MatchExpr{
+		cols: cols,
+	}
+}
+
+// Against 检索的内容，使用 BOOLEAN MODE
+func (m MatchExpr) Against(query string) MatchExpr {
+	m.query = query
+	return m
+}
+
+func (m MatchExpr) As(alias string) MatchExpr {
+	m.alias = alias
+	return m
+}
+
+func (m MatchExpr) fieldName() string {
+	return ""
+}
+
+func (m MatchExpr) target() TableReference {
+	return nil
+}
+
+func (m MatchExpr) selectedAlias() string {
+	return m.alias
+}
+
+func (MatchExpr) expr() {}
+
+// AsPredicate 用于 WHERE，匹配上的行才会返回
+func (m MatchExpr) AsPredicate() Predicate {
+	return Predicate{
+		left: m,
+	}
+}
+
+// GT 相关度大于 arg
+func (m MatchExpr) GT(arg any) Predicate { // > 大于
+	return Predicate{
+		left:  m,
+		opt:   optGT,
+		right: exprOf(arg),
+	}
+}
+
+func (b *builder) buildMatch(m MatchExpr, useAlias bool) error {
+	if err := b.dialect.buildMatch(b, m); err != nil {
+		return err
+	}
+	if useAlias {
+		b.buildAs(m.alias)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package morm
+
+import (
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	db := memoryDB(t)
+	sqliteDB := memoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "where",
+			q: NewSelector[TestModel](db).
+				Where(Match(C("FirstName"), C("LastName")).Against("+Tom -Jerry").AsPredicate()),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE MATCH(`first_name`,`last_name`) AGAINST(? IN BOOLEAN MODE);",
+				Args: []any{"+Tom -Jerry"},
+			},
+		},
+		{
+			name: "select and order by score",
+			q: func() QueryBuilder {
+				score := Match(C("FirstName")).Against("Tom")
+				return NewSelector[TestModel](db).
+					Select(C("Id"), score.As("score")).
+					Where(score.GT(0)).
+					OrderBy(DescExpr(Alias("score")))
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,MATCH(`first_name`) AGAINST(? IN BOOLEAN MODE) AS `score` FROM `test_model` WHERE MATCH(`first_name`) AGAINST(? IN BOOLEAN MODE) > ? ORDER BY `score` DESC;",
+				Args: []any{"Tom", "Tom", 0},
+			},
+		},
+		{
+			name: "order by",
+			q:    NewSelector[TestModel](db).OrderBy(DescExpr(Match(C("FirstName")).Against("Tom"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` ORDER BY MATCH(`first_name`) AGAINST(? IN BOOLEAN MODE) DESC;",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "table",
+			q: func() QueryBuilder {
+				t1 := TableOf(&TestModel{}).As("t1")
+				return NewSelector[TestModel](db).From(t1).
+					Where(Match(t1.C("FirstName")).Against("Tom").AsPredicate())
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` AS `t1` WHERE MATCH(`t1`.`first_name`) AGAINST(? IN BOOLEAN MODE);",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name:    "unknown field",
+			q:       NewSelector[TestModel](db).Where(Match(C("Invalid")).Against("Tom").AsPredicate()),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "sqlite",
+			q:       NewSelector[TestModel](sqliteDB).Where(Match(C("FirstName")).Against("Tom").AsPredicate()),
+			wantErr: errs.NewErrUnsupportedByDialect("SQLite", "MATCH AGAINST"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
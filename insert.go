@@ -2,8 +2,11 @@ package morm
 
 import (
 	"context"
+	"database/sql"
 	"github.com/NotFound1911/morm/errors"
 	"github.com/NotFound1911/morm/model"
+	"reflect"
+	"sort"
 )
 
 type UpsertBuilder[T any] struct {
@@ -38,6 +41,8 @@ type Inserter[T any] struct {
 	values      []*T     // 插入值
 	columns     []string // 指定列
 	onDuplicate *Upsert
+	batchSize   int  // 每条语句插入的行数
+	batchInTx   bool // 分批插入的时候是否使用同一个事务
 
 	sess session
 }
@@ -72,6 +77,7 @@ func (i *Inserter[T]) Cloumns(cols ...string) *Inserter[T] {
 	return i
 }
 func (i *Inserter[T]) Build() (*Query, error) {
+	i.reset()
 	if len(i.values) == 0 {
 		return nil, errs.NewErrInsertZeroRow()
 	}
@@ -84,10 +90,22 @@ func (i *Inserter[T]) Build() (*Query, error) {
 		return nil, err
 	}
 	i.sqlBuilder.WriteString("INSERT INTO ")
-	i.quote(i.model.TableName)
+	i.quote(i.tableName(i.model))
 	i.sqlBuilder.WriteString("(")
 
+	pk, err := i.autoIncrementField()
+	if err != nil {
+		return nil, err
+	}
 	fields := i.model.Fields
+	if len(i.columns) == 0 && pk != nil { // 主键交给数据库生成
+		fields = make([]*model.Field, 0, len(i.model.Fields)-1)
+		for _, fd := range i.model.Fields {
+			if fd != pk {
+				fields = append(fields, fd)
+			}
+		}
+	}
 	if len(i.columns) != 0 { // 指定列
 		fields = make([]*model.Field, 0, len(i.columns))
 		for _, col := range i.columns { // 使用sql的顺序
@@ -134,6 +152,10 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			return nil, err
 		}
 	}
+	if pk != nil && i.dialect.supportReturning() {
+		i.sqlBuilder.WriteString(" RETURNING ")
+		i.quote(pk.ColName)
+	}
 	i.sqlBuilder.WriteByte(';')
 	return &Query{
 		SQL:  i.sqlBuilder.String(),
@@ -159,15 +181,231 @@ func (i *Inserter[T]) buildAssignment(a Assignable) error {
 			return errs.NewErrUnknownField(assign.name)
 		}
 		i.sqlBuilder.WriteString(fd.ColName)
-		i.sqlBuilder.WriteByte('`')
-		i.sqlBuilder.WriteString("=?")
-		i.addArgs(assign.val)
+		i.sqlBuilder.WriteString("`=")
+		return i.buildExpression(assign.val)
 	default:
 		return errs.NewErrUnsupportedAssignableType(a)
 	}
 	return nil
 }
 
+// autoIncrementField 返回需要由数据库生成并回填的自增主键，不需要回填的时候返回 nil
+// 只有在没有 upsert，并且插入的列中不包含主键的时候才会回填：
+// 1. 指定了列，但是没有指定主键列；
+// 2. 没有指定列，但是所有值的主键都是零值，这时候构造 SQL 会跳过主键列
+func (i *Inserter[T]) autoIncrementField() (*model.Field, error) {
+	pk := i.model.PrimaryKey
+	if pk == nil || !pk.AutoIncrement || i.onDuplicate != nil {
+		return nil, nil
+	}
+	if len(i.columns) != 0 {
+		for _, col := range i.columns {
+			if col == pk.GoName {
+				return nil, nil
+			}
+		}
+		return pk, nil
+	}
+	for _, val := range i.values {
+		id, err := i.valCreator(val, i.model).Field(pk.GoName)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.ValueOf(id).IsZero() {
+			return nil, nil
+		}
+	}
+	return pk, nil
+}
+
+// Exec 执行插入，成功之后会将数据库生成的自增主键回填到 values 中
+// 支持 RETURNING 的方言直接读取返回的主键，按照从小到大的顺序依次回填；
+// 否则依赖于 MySQL 的约定：批量插入时 LastInsertId 是第一行的主键，后面的行依次递增
 func (i *Inserter[T]) Exec(ctx context.Context) Result {
-	return exec(ctx, i.sess, i.core, &QueryContext{Builder: i, Type: "INSERT"})
+	if sd, ok := i.sess.(*ShardingDB); ok {
+		return shardingInsert[T](ctx, sd, i)
+	}
+	var (
+		t   T
+		err error
+	)
+	i.model, err = i.r.Get(&t)
+	if err != nil {
+		return Result{err: err}
+	}
+	pk, err := i.autoIncrementField()
+	if err != nil {
+		return Result{err: err}
+	}
+	if pk == nil {
+		return exec(ctx, i.sess, i.core, &QueryContext{Builder: i, Type: "INSERT"})
+	}
+	if i.dialect.supportReturning() {
+		return i.execReturning(ctx, pk)
+	}
+	res := exec(ctx, i.sess, i.core, &QueryContext{Builder: i, Type: "INSERT"})
+	if res.err != nil {
+		return res
+	}
+	id, err := res.res.LastInsertId()
+	if err != nil {
+		return Result{err: err, res: res.res}
+	}
+	for idx, val := range i.values {
+		if err = i.valCreator(val, i.model).SetField(pk.GoName, id+int64(idx)); err != nil {
+			return Result{err: err, res: res.res}
+		}
+	}
+	return res
+}
+
+func (i *Inserter[T]) execReturning(ctx context.Context, pk *model.Field) Result {
+	// INSERT ... RETURNING 通过 queryContext 执行，但它是写操作，必须使用主库
+	ctx = UsePrimary(ctx)
+	var handler HanderFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Query()
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		rows, err := i.sess.queryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return &QueryResult{
+				Err: err,
+			}
+		}
+		defer func() { _ = rows.Close() }()
+		ids := make([]int64, 0, len(i.values))
+		for len(ids) < len(i.values) && rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				return &QueryResult{Err: err}
+			}
+			ids = append(ids, id)
+		}
+		if err = rows.Err(); err != nil {
+			return &QueryResult{Err: err}
+		}
+		// SQLite 不保证 RETURNING 返回的顺序，返回的行里面也没有可以和 values 对应的列，
+		// 所以依赖于自增主键的约定：同一条语句中按照 VALUES 的顺序分配递增的主键，排序之后和 values 一一对应
+		sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+		var res sqlResult
+		for idx, id := range ids {
+			if err = i.valCreator(i.values[idx], i.model).SetField(pk.GoName, id); err != nil {
+				return &QueryResult{Err: err}
+			}
+			res.lastInsertId = id
+			res.rowsAffected++
+		}
+		return &QueryResult{Result: res}
+	}
+	ms := i.ms
+	for idx := len(ms) - 1; idx >= 0; idx-- {
+		handler = ms[idx](handler)
+	}
+	qr := handler(ctx, &QueryContext{Builder: i, Type: "INSERT"})
+	var res sql.Result
+	if qr.Result != nil {
+		res = qr.Result.(sql.Result)
+	}
+	return Result{
+		err: qr.Err,
+		res: res,
+	}
+}
+
+// BatchSize 设置 ExecBatches 每条语句最多插入的行数
+// 实际的行数还会受到方言占位符数量上限的约束，n <= 0 的时候只受方言约束
+func (i *Inserter[T]) BatchSize(n int) *Inserter[T] {
+	i.batchSize = n
+	return i
+}
+
+// BatchInTx 让 ExecBatches 的所有语句在同一个事务中执行
+// 如果 Inserter 本身就是在事务中创建的，或者 ctx 中已经有事务，那么直接使用该事务
+func (i *Inserter[T]) BatchInTx() *Inserter[T] {
+	i.batchInTx = true
+	return i
+}
+
+// ExecBatches 将 values 拆分成多条 INSERT 语句执行，并汇总 RowsAffected
+// LastInsertId 是最后一条语句的结果，自增主键会和 Exec 一样回填
+func (i *Inserter[T]) ExecBatches(ctx context.Context) Result {
+	if len(i.values) == 0 {
+		return Result{err: errs.NewErrInsertZeroRow()}
+	}
+	var (
+		t   T
+		err error
+	)
+	i.model, err = i.r.Get(&t)
+	if err != nil {
+		return Result{err: err}
+	}
+	size := i.batchRows()
+	db, ok := i.sess.(*DB)
+	if !i.batchInTx || !ok {
+		return i.execBatches(ctx, i.sess, size)
+	}
+	if _, inTx := db.TxFromContext(ctx); inTx {
+		// DB 执行的时候会使用 ctx 中的事务
+		return i.execBatches(ctx, db, size)
+	}
+	var res Result
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		res = i.execBatches(ctx, tx, size)
+		return res.err
+	}, nil)
+	if err != nil {
+		res.err = err
+	}
+	return res
+}
+
+// batchRows 计算每条语句插入的行数
+func (i *Inserter[T]) batchRows() int {
+	cnt := len(i.model.Fields)
+	if len(i.columns) != 0 {
+		cnt = len(i.columns)
+	}
+	limit := i.dialect.maxParams()
+	if i.onDuplicate != nil {
+		// UPSERT 的赋值语句也会占用占位符
+		limit -= len(i.onDuplicate.assigns)
+	}
+	rows := limit / cnt
+	if rows < 1 {
+		rows = 1
+	}
+	if i.batchSize > 0 && i.batchSize < rows {
+		rows = i.batchSize
+	}
+	return rows
+}
+
+func (i *Inserter[T]) execBatches(ctx context.Context, sess session, size int) Result {
+	var sum sqlResult
+	for start := 0; start < len(i.values); start += size {
+		end := start + size
+		if end > len(i.values) {
+			end = len(i.values)
+		}
+		sub := NewInserter[T](sess).Values(i.values[start:end]...).Cloumns(i.columns...)
+		sub.onDuplicate = i.onDuplicate
+		res := sub.Exec(ctx)
+		if res.err != nil {
+			return Result{err: res.err, res: sum}
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return Result{err: err, res: sum}
+		}
+		sum.rowsAffected += affected
+		// 有些驱动在没有自增列的时候不支持 LastInsertId，忽略错误
+		if id, err := res.LastInsertId(); err == nil {
+			sum.lastInsertId = id
+		}
+	}
+	return Result{res: sum}
 }
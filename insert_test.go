@@ -1,9 +1,13 @@
 package morm
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/NotFound1911/morm/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -57,6 +61,31 @@ func TestInserter_Build(t *testing.T) {
 					int64(2), "practice", int8(20), &sql.NullString{String: "do", Valid: true}},
 			},
 		},
+		// 自增主键交给数据库生成
+		{
+			name: "auto increment",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{FirstName: "test", Age: 19},
+				&TestModel{FirstName: "practice", Age: 20},
+			),
+			wantQuerry: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`age`,`last_name`) VALUES(?,?,?),(?,?,?);",
+				Args: []any{"test", int8(19), (*sql.NullString)(nil), "practice", int8(20), (*sql.NullString)(nil)},
+			},
+		},
+		{
+			// 只要有一个值指定了主键，就不能交给数据库生成
+			name: "partial auto increment",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{FirstName: "test", Age: 19},
+				&TestModel{Id: 2, FirstName: "practice", Age: 20},
+			),
+			wantQuerry: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?),(?,?,?,?);",
+				Args: []any{int64(0), "test", int8(19), (*sql.NullString)(nil),
+					int64(2), "practice", int8(20), (*sql.NullString)(nil)},
+			},
+		},
 		// 指定列
 		{
 			name: "specify  columns",
@@ -96,7 +125,17 @@ func TestInserter_Build(t *testing.T) {
 			wantQuerry: &Query{
 				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?) " +
 					"ON DUPLICATE KEY UPDATE `first_name`=?;",
-				Args: []any{int64(1), "test", int8(19), &sql.NullString{String: "do", Valid: true}, value{val: "practice"}},
+				Args: []any{int64(1), "test", int8(19), &sql.NullString{String: "do", Valid: true}, "practice"},
+			},
+		},
+		{
+			name: "upsert case",
+			q: NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "test", Age: 19}).
+				OnDuplicateKey().Update(Assign("Age", Case().When(C("Age").LT(18), 18).Else(C("Age")))),
+			wantQuerry: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?) " +
+					"ON DUPLICATE KEY UPDATE `age`=CASE WHEN `age` < ? THEN ? ELSE `age` END;",
+				Args: []any{int64(1), "test", int8(19), (*sql.NullString)(nil), 18, 18},
 			},
 		},
 		{
@@ -166,7 +205,42 @@ func TestUpsert_SQLite3_Build(t *testing.T) {
 			wantQuery: &Query{
 				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?) " +
 					"ON CONFLICT(`id`) DO UPDATE SET `first_name`=?;",
-				Args: []any{int64(1), "test", int8(19), &sql.NullString{String: "do", Valid: true}, value{val: "practice"}},
+				Args: []any{int64(1), "test", int8(19), &sql.NullString{String: "do", Valid: true}, "practice"},
+			},
+		},
+		{
+			name: "upsert func",
+			q: NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "test", Age: 19}).
+				OnDuplicateKey().ConflictColumns("Id").
+				Update(Assign("FirstName", Func("LOWER", C("FirstName")))),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?) " +
+					"ON CONFLICT(`id`) DO UPDATE SET `first_name`=LOWER(`first_name`);",
+				Args: []any{int64(1), "test", int8(19), (*sql.NullString)(nil)},
+			},
+		},
+		{
+			// 自增主键通过 RETURNING 返回
+			name: "returning",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{FirstName: "test", Age: 19},
+				&TestModel{FirstName: "practice", Age: 20},
+			),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`age`,`last_name`) VALUES(?,?,?),(?,?,?) RETURNING `id`;",
+				Args: []any{"test", int8(19), (*sql.NullString)(nil), "practice", int8(20), (*sql.NullString)(nil)},
+			},
+		},
+		{
+			// upsert 不回填主键
+			name: "upsert without returning",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{FirstName: "test", Age: 19}).
+				OnDuplicateKey().ConflictColumns("Id").Update(C("FirstName")),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?) " +
+					"ON CONFLICT(`id`) DO UPDATE SET `first_name`=excluded.`first_name`;",
+				Args: []any{int64(0), "test", int8(19), (*sql.NullString)(nil)},
 			},
 		},
 		{
@@ -232,3 +306,308 @@ func TestUpsert_SQLite3_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestInserter_Exec_LastInsertId(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name     string
+		values   []*TestModel
+		mockRes  sql.Result
+		wantIds  []int64
+		wantErr  error
+		affected int64
+	}{
+		{
+			// MySQL 批量插入的时候 LastInsertId 是第一行的主键
+			name:     "multiple values",
+			values:   []*TestModel{{FirstName: "a"}, {FirstName: "b"}, {FirstName: "c"}},
+			mockRes:  sqlmock.NewResult(10, 3),
+			wantIds:  []int64{10, 11, 12},
+			affected: 3,
+		},
+		{
+			// 指定了主键不回填
+			name:     "specify id",
+			values:   []*TestModel{{Id: 3, FirstName: "a"}},
+			mockRes:  sqlmock.NewResult(10, 1),
+			wantIds:  []int64{3},
+			affected: 1,
+		},
+	}
+	for _, tc := range testCases {
+		mock.ExpectExec("INSERT .*").WillReturnResult(tc.mockRes)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := NewInserter[TestModel](db).Values(tc.values...).Exec(context.Background())
+			affected, err := res.RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.affected, affected)
+			ids := make([]int64, 0, len(tc.values))
+			for _, val := range tc.values {
+				ids = append(ids, val.Id)
+			}
+			assert.Equal(t, tc.wantIds, ids)
+		})
+	}
+}
+
+func TestInserter_Exec_Returning(t *testing.T) {
+	db := memoryDBWithDB("inserter_returning", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	vals := []*TestModel{
+		{FirstName: "a", LastName: &sql.NullString{String: "A", Valid: true}},
+		{FirstName: "b", LastName: &sql.NullString{String: "B", Valid: true}},
+	}
+	res := NewInserter[TestModel](db).Values(vals...).Exec(context.Background())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, int64(1), vals[0].Id)
+	assert.Equal(t, int64(2), vals[1].Id)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+
+	got, err := NewSelector[TestModel](db).Where(C("Id").EQ(vals[1].Id)).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "b", got.FirstName)
+}
+
+func TestInserter_Exec_ReturningOrder(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithDialect(SQLite3))
+	require.NoError(t, err)
+
+	// RETURNING 返回的顺序和插入的顺序不一致，按照主键的大小回填
+	mock.ExpectQuery("INSERT .* RETURNING `id`;").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12).AddRow(10).AddRow(11))
+	vals := []*TestModel{{FirstName: "a"}, {FirstName: "b"}, {FirstName: "c"}}
+	res := NewInserter[TestModel](db).Values(vals...).Exec(context.Background())
+	require.NoError(t, res.Err())
+	assert.Equal(t, int64(10), vals[0].Id)
+	assert.Equal(t, int64(11), vals[1].Id)
+	assert.Equal(t, int64(12), vals[2].Id)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), id)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInserter_batchRows(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dialect  Dialect
+		i        func(db *DB) *Inserter[TestModel]
+		wantRows int
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db)
+			},
+			wantRows: 65535 / 4,
+		},
+		{
+			name:    "sqlite3",
+			dialect: SQLite3,
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db)
+			},
+			wantRows: 32766 / 4,
+		},
+		{
+			name:    "specify columns",
+			dialect: SQLite3,
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db).Cloumns("FirstName", "Age", "LastName")
+			},
+			wantRows: 32766 / 3,
+		},
+		{
+			name:    "upsert",
+			dialect: SQLite3,
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db).OnDuplicateKey().
+					Update(Assign("FirstName", "a"), Assign("Age", 1))
+			},
+			wantRows: (32766 - 2) / 4,
+		},
+		{
+			name:    "batch size",
+			dialect: MySQL,
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db).BatchSize(100)
+			},
+			wantRows: 100,
+		},
+		{
+			// 超过方言限制的时候以方言为准
+			name:    "batch size too large",
+			dialect: SQLite3,
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db).BatchSize(100000)
+			},
+			wantRows: 32766 / 4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			i := tc.i(memoryDB(t, DBWithDialect(tc.dialect)))
+			m, err := i.r.Get(&TestModel{})
+			require.NoError(t, err)
+			i.model = m
+			assert.Equal(t, tc.wantRows, i.batchRows())
+		})
+	}
+}
+
+func TestInserter_ExecBatches(t *testing.T) {
+	values := func() []*TestModel {
+		return []*TestModel{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}, {Id: 5}}
+	}
+	testCases := []struct {
+		name     string
+		mock     func(mock sqlmock.Sqlmock)
+		i        func(db *DB) *Inserter[TestModel]
+		wantErr  error
+		affected int64
+	}{
+		{
+			name: "no value",
+			mock: func(mock sqlmock.Sqlmock) {},
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db)
+			},
+			wantErr: errs.NewErrInsertZeroRow(),
+		},
+		{
+			name: "batches",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT .* VALUES\\(\\?,\\?,\\?,\\?\\),\\(\\?,\\?,\\?,\\?\\);").
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectExec("INSERT .* VALUES\\(\\?,\\?,\\?,\\?\\),\\(\\?,\\?,\\?,\\?\\);").
+					WillReturnResult(sqlmock.NewResult(4, 2))
+				mock.ExpectExec("INSERT .* VALUES\\(\\?,\\?,\\?,\\?\\);").
+					WillReturnResult(sqlmock.NewResult(5, 1))
+			},
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db).Values(values()...).BatchSize(2)
+			},
+			affected: 5,
+		},
+		{
+			name: "in tx",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(3, 3))
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(5, 2))
+				mock.ExpectCommit()
+			},
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db).Values(values()...).BatchSize(3).BatchInTx()
+			},
+			affected: 5,
+		},
+		{
+			name: "rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(3, 3))
+				mock.ExpectExec("INSERT .*").WillReturnError(errors.New("mock error"))
+				mock.ExpectRollback()
+			},
+			i: func(db *DB) *Inserter[TestModel] {
+				return NewInserter[TestModel](db).Values(values()...).BatchSize(3).BatchInTx()
+			},
+			wantErr: errors.New("mock error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB)
+			require.NoError(t, err)
+			tc.mock(mock)
+			res := tc.i(db).ExecBatches(context.Background())
+			affected, err := res.RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.affected, affected)
+		})
+	}
+}
+
+func TestInserter_ExecBatches_TxFromContext(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// 使用 ctx 中的事务，不会开启新的事务
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(3, 3))
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(5, 2))
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	vals := make([]*TestModel, 0, 5)
+	for i := int64(1); i <= 5; i++ {
+		vals = append(vals, &TestModel{Id: i, FirstName: "Tom", Age: 18})
+	}
+	err = db.DoTxCtx(context.Background(), func(ctx context.Context) error {
+		res := NewInserter[TestModel](db).Values(vals...).BatchSize(3).BatchInTx().ExecBatches(ctx)
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, int64(5), affected)
+		return NewUpdater[TestModel](db).Set(Assign("Age", 19)).Exec(ctx).Err()
+	}, PropagationRequired)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInserter_Upsert_Case(t *testing.T) {
+	db := memoryDBWithDB("inserter_upsert_case", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	ctx := context.Background()
+	res := NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "a", Age: 16,
+		LastName: &sql.NullString{String: "A", Valid: true}}).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	// 冲突的时候年龄至少是 18
+	res = NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: "b", Age: 20,
+		LastName: &sql.NullString{String: "B", Valid: true}}).
+		OnDuplicateKey().ConflictColumns("Id").
+		Update(Assign("Age", Case().When(C("Age").LT(18), 18).Else(C("Age")))).Exec(ctx)
+	require.NoError(t, res.Err())
+	got, err := NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.FirstName)
+	assert.Equal(t, int8(18), got.Age)
+}
@@ -21,6 +21,19 @@ func (r reflectValue) Field(name string) (any, error) {
 	return res.Interface(), nil
 }
 
+func (r reflectValue) SetField(name string, val any) error {
+	fd := r.val.FieldByName(name)
+	if fd == (reflect.Value{}) {
+		return errs.NewErrUnknownField(name)
+	}
+	v, err := convert(name, val, fd.Type())
+	if err != nil {
+		return err
+	}
+	fd.Set(v)
+	return nil
+}
+
 var _ Creator = NewReflectValue
 
 // NewReflectValue 返回一个封装好的，基于反射实现的 Value
@@ -37,6 +37,20 @@ func (u unsafeValue) Field(name string) (any, error) {
 	return val.Interface(), nil
 }
 
+func (u unsafeValue) SetField(name string, val any) error {
+	fd, ok := u.meta.FieldMap[name]
+	if !ok {
+		return errs.NewErrUnknownField(name)
+	}
+	v, err := convert(name, val, fd.Type)
+	if err != nil {
+		return err
+	}
+	ptr := unsafe.Pointer(uintptr(u.addr) + fd.Offset)
+	reflect.NewAt(fd.Type, ptr).Elem().Set(v)
+	return nil
+}
+
 var _ Creator = NewUnsafeValue
 
 func NewUnsafeValue(val interface{}, meta *model.Model) Value {
@@ -62,7 +62,7 @@ func Test_unsafeValueSetColumn(t *testing.T) {
 				"json_column":      []byte(`{"name": "Tom"}`),
 			},
 			val:     &test.SimpleStruct{},
-			wantVal: test.NewSimpleStruct(),
+			wantVal: test.NewSimpleStruct(1),
 		},
 		{
 			name: "invalid field",
@@ -106,3 +106,68 @@ func Test_unsafeValueSetColumn(t *testing.T) {
 		})
 	}
 }
+
+func Test_unsafeValueSetField(t *testing.T) {
+	testCases := []struct {
+		name    string
+		field   string
+		val     any
+		wantVal *test.SimpleStruct
+		wantErr error
+	}{
+		{
+			name:    "same type",
+			field:   "Id",
+			val:     uint64(12),
+			wantVal: &test.SimpleStruct{Id: 12},
+		},
+		{
+			// LastInsertId 返回的是 int64
+			name:    "convertible type",
+			field:   "Id",
+			val:     int64(13),
+			wantVal: &test.SimpleStruct{Id: 13},
+		},
+		{
+			name:    "invalid field",
+			field:   "Invalid",
+			val:     int64(13),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "invalid value",
+			field:   "Id",
+			val:     "abc",
+			wantErr: errs.NewErrInvalidFieldValue("Id", "abc"),
+		},
+		{
+			// int64 可以转换为 string，但是结果是字符
+			name:    "string field",
+			field:   "String",
+			val:     int64(1),
+			wantErr: errs.NewErrInvalidFieldValue("String", int64(1)),
+		},
+		{
+			name:    "float field",
+			field:   "Float64",
+			val:     int64(1),
+			wantErr: errs.NewErrInvalidFieldValue("Float64", int64(1)),
+		},
+	}
+	r := model.NewRegistry()
+	meta, err := r.Get(&test.SimpleStruct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entity := &test.SimpleStruct{}
+			err := NewUnsafeValue(entity, meta).SetField(tc.field, tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, entity)
+		})
+	}
+}
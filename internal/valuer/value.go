@@ -16,7 +16,9 @@ package valuer
 
 import (
 	"database/sql"
+	"github.com/NotFound1911/morm/errors"
 	"github.com/NotFound1911/morm/model"
+	"reflect"
 )
 
 // Value 是结构体实例在内存中的具体数据表示
@@ -25,5 +27,26 @@ type Value interface {
 	Field(name string) (any, error)
 	// SetColumns 设置列值
 	SetColumns(rows *sql.Rows) error
+	// SetField 设置字段的值，val 的类型需要能够转换为字段的类型
+	SetField(name string, val any) error
 }
 type Creator func(val interface{}, meta *model.Model) Value
+
+// convert 将 val 转换为字段类型 typ，例如将 LastInsertId 返回的 int64 转换为 uint64
+// 只支持整数之间的转换，reflect 允许 int64 转换为 string，但是得到的是对应的字符，例如 "\x01"
+func convert(name string, val any, typ reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(val)
+	if !v.IsValid() || !isInteger(v.Kind()) || !isInteger(typ.Kind()) {
+		return reflect.Value{}, errs.NewErrInvalidFieldValue(name, val)
+	}
+	return v.Convert(typ), nil
+}
+
+func isInteger(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package morm
+
+import "regexp"
+
+var _ Selectable = JSONExpr{}
+var _ Expression = JSONExpr{}
+
+// JSONExpr 取 JSON 列中 path 对应的值，例如 C("Attrs").JSON("$.color")
+// path 一般使用占位符传入，只有 MySQL 的 ->> 不允许使用占位符，这时候 path 会经过 validJSONPath 校验
+type JSONExpr struct {
+	col  Column
+	path string
+	// unquote 为 true 代表取出来的是去掉引号的文本，而不是 JSON 值
+	unquote bool
+	alias   string
+}
+
+// JSON 对应 JSON_EXTRACT(`col`,'$.a.b')
+func (c Column) JSON(path string) JSONExpr {
+	return JSONExpr{
+		col:  c,
+		path: path,
+	}
+}
+
+// JSONText 对应 MySQL 的 `col`->>'$.a'，即 JSON_UNQUOTE(JSON_EXTRACT(...))
+func (c Column) JSONText(path string) JSONExpr {
+	return JSONExpr{
+		col:     c,
+		path:    path,
+		unquote: true,
+	}
+}
+
+func (j JSONExpr) As(alias string) JSONExpr {
+	j.alias = alias
+	return j
+}
+
+func (j JSONExpr) fieldName() string {
+	return ""
+}
+
+func (j JSONExpr) target() TableReference {
+	return nil
+}
+
+func (j JSONExpr) selectedAlias() string {
+	return j.alias
+}
+
+func (JSONExpr) expr() {}
+
+func (j JSONExpr) EQ(arg any) Predicate { // = 等于
+	return Predicate{
+		left:  j,
+		opt:   optEQ,
+		right: exprOf(arg),
+	}
+}
+func (j JSONExpr) LT(arg any) Predicate { // < 小于
+	return Predicate{
+		left:  j,
+		opt:   optLT,
+		right: exprOf(arg),
+	}
+}
+func (j JSONExpr) GT(arg any) Predicate { // > 大于
+	return Predicate{
+		left:  j,
+		opt:   optGT,
+		right: exprOf(arg),
+	}
+}
+
+func (b *builder) buildJSON(j JSONExpr, useAlias bool) error {
+	if err := b.dialect.buildJSONExtract(b, j); err != nil {
+		return err
+	}
+	if useAlias {
+		b.buildAs(j.alias)
+	}
+	return nil
+}
+
+// jsonPathRegexp 只允许 $、.key、.*、[n] 和 [*]，key 只能由字母、数字和下划线组成
+var jsonPathRegexp = regexp.MustCompile(`^\$(\.[A-Za-z_][A-Za-z0-9_]*|\.\*|\[[0-9]+\]|\[\*\])*$`)
+
+// validJSONPath 校验需要作为字面量输出的 path，避免 SQL 注入
+func validJSONPath(path string) bool {
+	return jsonPathRegexp.MatchString(path)
+}
@@ -0,0 +1,144 @@
+package morm
+
+import (
+	"context"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type Product struct {
+	Id    int64
+	Name  string
+	Attrs string
+}
+
+func (Product) CreateSQL() string {
+	return `
+CREATE TABLE IF NOT EXISTS product(
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    attrs TEXT NOT NULL
+)
+`
+}
+
+func TestJSONExpr(t *testing.T) {
+	db := memoryDB(t)
+	sqliteDB := memoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "where",
+			q:    NewSelector[Product](db).Where(C("Attrs").JSON("$.size.width").GT(10)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_EXTRACT(`attrs`,?) > ?;",
+				Args: []any{"$.size.width", 10},
+			},
+		},
+		{
+			name: "text",
+			q:    NewSelector[Product](db).Where(C("Attrs").JSONText("$.color").EQ("red")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE `attrs`->>'$.color' = ?;",
+				Args: []any{"red"},
+			},
+		},
+		{
+			name: "select and order by",
+			q: NewSelector[Product](db).
+				Select(C("Id"), C("Attrs").JSONText("$.color").As("color")).
+				OrderBy(AscExpr(C("Attrs").JSON("$.size.width"))),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,`attrs`->>'$.color' AS `color` FROM `product` ORDER BY JSON_EXTRACT(`attrs`,?) ASC;",
+				Args: []any{"$.size.width"},
+			},
+		},
+		{
+			name: "table",
+			q: func() QueryBuilder {
+				p := TableOf(&Product{}).As("p")
+				return NewSelector[Product](db).From(p).Where(p.C("Attrs").JSON("$.color").EQ("red"))
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` AS `p` WHERE JSON_EXTRACT(`p`.`attrs`,?) = ?;",
+				Args: []any{"$.color", "red"},
+			},
+		},
+		{
+			// path 使用占位符，不会拼接到 SQL 中
+			name: "hostile path",
+			q:    NewSelector[Product](db).Where(C("Attrs").JSON(`$.a\' OR 1=1 -- `).EQ(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE JSON_EXTRACT(`attrs`,?) = ?;",
+				Args: []any{`$.a\' OR 1=1 -- `, 1},
+			},
+		},
+		{
+			// ->> 只能使用字面量，所以 path 必须符合 JSONPath 的语法
+			name:    "hostile text path",
+			q:       NewSelector[Product](db).Where(C("Attrs").JSONText(`$.a\' OR 1=1 -- `).EQ(1)),
+			wantErr: errs.NewErrInvalidJSONPath(`$.a\' OR 1=1 -- `),
+		},
+		{
+			name:    "text path with quote",
+			q:       NewSelector[Product](db).Select(C("Attrs").JSONText("$.a'b")),
+			wantErr: errs.NewErrInvalidJSONPath("$.a'b"),
+		},
+		{
+			name: "text path with index",
+			q:    NewSelector[Product](db).Select(C("Attrs").JSONText("$.tags[0].name")),
+			wantQuery: &Query{
+				SQL: "SELECT `attrs`->>'$.tags[0].name' FROM `product`;",
+			},
+		},
+		{
+			name: "sqlite",
+			q: NewSelector[Product](sqliteDB).
+				Where(C("Attrs").JSONText("$.color").EQ("red")).
+				OrderBy(DescExpr(C("Attrs").JSON("$.size.width"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `product` WHERE json_extract(`attrs`,?) = ? ORDER BY json_extract(`attrs`,?) DESC;",
+				Args: []any{"$.color", "red", "$.size.width"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestJSONExpr_SQLite3_GetMulti(t *testing.T) {
+	db := memoryDBWithDB("json_expr", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(Product{}.CreateSQL())
+	require.NoError(t, err)
+	res := NewInserter[Product](db).Values(
+		&Product{Id: 1, Name: "a", Attrs: `{"color":"red","size":{"width":10}}`},
+		&Product{Id: 2, Name: "b", Attrs: `{"color":"blue","size":{"width":30}}`},
+		&Product{Id: 3, Name: "c", Attrs: `{"color":"red","size":{"width":20}}`},
+	).Exec(context.Background())
+	require.NoError(t, res.Err())
+
+	got, err := NewSelector[Product](db).
+		Where(C("Attrs").JSONText("$.color").EQ("red")).
+		OrderBy(DescExpr(C("Attrs").JSON("$.size.width"))).
+		GetMulti(context.Background())
+	require.NoError(t, err)
+	names := make([]string, 0, len(got))
+	for _, p := range got {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"c", "a"}, names)
+}
@@ -0,0 +1,56 @@
+package morm
+
+// 锁定读的模式
+const (
+	lockForUpdate = "FOR UPDATE"
+	lockForShare  = "FOR SHARE"
+)
+
+// 锁等待策略
+const (
+	lockNoWait     = "NOWAIT"
+	lockSkipLocked = "SKIP LOCKED"
+)
+
+// lock 对应 SELECT ... FOR UPDATE [NOWAIT | SKIP LOCKED]
+type lock struct {
+	mode string
+	wait string
+}
+
+// LockOption 锁定读的选项
+type LockOption func(l *lock)
+
+// NoWait 拿不到锁的时候立刻返回错误
+func NoWait() LockOption {
+	return func(l *lock) {
+		l.wait = lockNoWait
+	}
+}
+
+// SkipLocked 跳过已经被锁住的行，常用于任务队列
+func SkipLocked() LockOption {
+	return func(l *lock) {
+		l.wait = lockSkipLocked
+	}
+}
+
+// ForUpdate 对应 FOR UPDATE，只能在事务中使用
+func (s *Selector[T]) ForUpdate(opts ...LockOption) *Selector[T] {
+	s.lock = newLock(lockForUpdate, opts)
+	return s
+}
+
+// ForShare 对应 FOR SHARE，只能在事务中使用
+func (s *Selector[T]) ForShare(opts ...LockOption) *Selector[T] {
+	s.lock = newLock(lockForShare, opts)
+	return s
+}
+
+func newLock(mode string, opts []LockOption) *lock {
+	l := &lock{mode: mode}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
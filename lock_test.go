@@ -0,0 +1,103 @@
+package morm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSelector_Lock(t *testing.T) {
+	db := memoryDB(t)
+	sqliteDB := memoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "for update",
+			q:    NewSelector[TestModel](db).Where(C("Id").EQ(1)).ForUpdate(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ? FOR UPDATE;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "for share",
+			q:    NewSelector[TestModel](db).Where(C("Id").EQ(1)).ForShare(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ? FOR SHARE;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "for update nowait",
+			q:    NewSelector[TestModel](db).ForUpdate(NoWait()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` FOR UPDATE NOWAIT;",
+			},
+		},
+		{
+			name: "for update skip locked",
+			q:    NewSelector[TestModel](db).Limit(10).ForUpdate(SkipLocked()),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` LIMIT ? FOR UPDATE SKIP LOCKED;",
+				Args: []any{10},
+			},
+		},
+		{
+			name: "for share skip locked",
+			q:    NewSelector[TestModel](db).ForShare(SkipLocked()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` FOR SHARE SKIP LOCKED;",
+			},
+		},
+		{
+			// SQLite 锁的是整个数据库，忽略锁定读
+			name: "sqlite",
+			q:    NewSelector[TestModel](sqliteDB).ForUpdate(SkipLocked()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_LockOutsideTx(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	_, err = NewSelector[TestModel](db).ForUpdate().Get(context.Background())
+	assert.Equal(t, errs.NewErrLockOutsideTx("FOR UPDATE"), err)
+	_, err = NewSelector[TestModel](db).ForShare().GetMulti(context.Background())
+	assert.Equal(t, errs.NewErrLockOutsideTx("FOR SHARE"), err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FOR UPDATE;").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	res, err := NewSelector[TestModel](tx).ForUpdate().Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.Id)
+	require.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -11,6 +11,23 @@ type QueryContext struct {
 
 	Builder QueryBuilder
 	Model   *model.Model
+
+	// q 缓存构造的结果，中间件和最终的执行共用一次构造
+	q *Query
+}
+
+// Query 构造 SQL 和参数，只会构造一次
+// 中间件如果要替换 Builder，需要在调用 Query 之前
+func (qc *QueryContext) Query() (*Query, error) {
+	if qc.q != nil {
+		return qc.q, nil
+	}
+	q, err := qc.Builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	qc.q = q
+	return q, nil
 }
 
 type QueryResult struct {
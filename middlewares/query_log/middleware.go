@@ -27,7 +27,7 @@ func (m *MiddlewareBuilder) LogFunc(fn func(query string, args []any)) *Middlewa
 func (m *MiddlewareBuilder) Build() morm.Middleware {
 	return func(next morm.HanderFunc) morm.HanderFunc {
 		return func(ctx context.Context, qc *morm.QueryContext) *morm.QueryResult {
-			q, err := qc.Builder.Build() // 构造SQL和参数
+			q, err := qc.Query() // 构造SQL和参数
 			if err != nil {
 				return &morm.QueryResult{
 					Err: err,
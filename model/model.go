@@ -13,6 +13,8 @@ type Model struct {
 	TableName string            // 表名
 	ColumnMap map[string]*Field // 列名（sql）
 	Fields    []*Field
+	// PrimaryKey 主键，没有主键的时候为 nil
+	PrimaryKey *Field
 }
 
 // Field 字段
@@ -25,6 +27,8 @@ type Field struct {
 	// Go字段名
 	GoName string
 	Index  int
+	// AutoIncrement 是否为自增列，只有主键才会被标记
+	AutoIncrement bool
 }
 
 // underscoreName 驼峰转字符串命名
@@ -46,7 +50,9 @@ func underscoreName(name string) string {
 
 // 支持的tag 标签
 const (
-	tagKeyColumn = "column"
+	tagKeyColumn        = "column"
+	tagKeyPrimaryKey    = "primary_key"
+	tagKeyAutoIncrement = "auto_increment"
 )
 
 // TableName 用户实现这个接口来返回自定义的表名
@@ -25,6 +25,8 @@ func (TestModel) IdField() *Field {
 		GoName:  "Id",
 		Offset:  0,
 		Index:   0,
+
+		AutoIncrement: true,
 	}
 }
 
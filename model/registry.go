@@ -3,6 +3,7 @@ package model
 import (
 	"github.com/NotFound1911/morm/errors"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -60,6 +61,11 @@ func (r *registry) parseModel(val any) (*Model, error) {
 	fdsMap := make(map[string]*Field, numField)
 	colsMap := make(map[string]*Field, numField)
 	fds := make([]*Field, numField)
+	var pk *Field
+	// autoIncr 记录显式设置了 auto_increment 的字段
+	autoIncr := make(map[*Field]bool, 1)
+	// notPk 记录显式设置了 primary_key=false 的字段
+	notPk := make(map[*Field]bool, 1)
 	for i := 0; i < numField; i++ {
 		fdType := typ.Field(i)
 		// 解析tag
@@ -81,6 +87,44 @@ func (r *registry) parseModel(val any) (*Model, error) {
 		fdsMap[fdType.Name] = f
 		colsMap[colName] = f
 		fds[i] = f
+		if val, ok := tags[tagKeyPrimaryKey]; ok {
+			isPk, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, errs.NewErrInvalidTagContent(tagKeyPrimaryKey + "=" + val)
+			}
+			if isPk {
+				if pk != nil {
+					return nil, errs.NewErrMultiplePrimaryKeys(pk.GoName, f.GoName)
+				}
+				pk = f
+			} else {
+				notPk[f] = true
+			}
+		}
+		if val, ok := tags[tagKeyAutoIncrement]; ok {
+			incr, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, errs.NewErrInvalidTagContent(tagKeyAutoIncrement + "=" + val)
+			}
+			autoIncr[f] = incr
+		}
+	}
+	// 没有通过标签指定主键的时候，约定名为 Id 或者 ID 的字段为主键
+	if pk == nil {
+		for _, name := range []string{"Id", "ID"} {
+			if fd, ok := fdsMap[name]; ok && !notPk[fd] {
+				pk = fd
+				break
+			}
+		}
+	}
+	// 整数类型的主键默认是自增的，除非显式设置 auto_increment=false
+	if pk != nil {
+		incr, ok := autoIncr[pk]
+		if !ok {
+			incr = isInteger(pk.Type)
+		}
+		pk.AutoIncrement = incr
 	}
 	var tableName string
 	if tn, ok := val.(TableName); ok {
@@ -90,12 +134,22 @@ func (r *registry) parseModel(val any) (*Model, error) {
 		tableName = underscoreName(typ.Name())
 	}
 	return &Model{
-		TableName: tableName,
-		FieldMap:  fdsMap,
-		ColumnMap: colsMap,
-		Fields:    fds,
+		TableName:  tableName,
+		FieldMap:   fdsMap,
+		ColumnMap:  colsMap,
+		Fields:     fds,
+		PrimaryKey: pk,
 	}, nil
 }
+
+func isInteger(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
 func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 	ormTag := tag.Get("morm")
 	if ormTag == "" {
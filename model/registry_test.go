@@ -41,7 +41,8 @@ func TestRegistry_get(t *testing.T) {
 					"age":        tm.AgeField(),
 					"last_name":  tm.LastNameField(),
 				},
-				Fields: []*Field{tm.IdField(), tm.FirstNameField(), tm.AgeField(), tm.LastNameField()},
+				Fields:     []*Field{tm.IdField(), tm.FirstNameField(), tm.AgeField(), tm.LastNameField()},
+				PrimaryKey: tm.IdField(),
 			},
 		},
 		{
@@ -81,6 +82,8 @@ func TestRegistry_get(t *testing.T) {
 						ColName: "id",
 						Type:    reflect.TypeOf(uint64(0)),
 						GoName:  "ID",
+
+						AutoIncrement: true,
 					},
 				},
 				ColumnMap: map[string]*Field{
@@ -88,6 +91,8 @@ func TestRegistry_get(t *testing.T) {
 						ColName: "id",
 						Type:    reflect.TypeOf(uint64(0)),
 						GoName:  "ID",
+
+						AutoIncrement: true,
 					},
 				},
 				Fields: []*Field{
@@ -95,8 +100,17 @@ func TestRegistry_get(t *testing.T) {
 						ColName: "id",
 						Type:    reflect.TypeOf(uint64(0)),
 						GoName:  "ID",
+
+						AutoIncrement: true,
 					},
 				},
+				PrimaryKey: &Field{
+					ColName: "id",
+					Type:    reflect.TypeOf(uint64(0)),
+					GoName:  "ID",
+
+					AutoIncrement: true,
+				},
 			},
 		},
 		{
@@ -178,6 +192,105 @@ func TestRegistry_get(t *testing.T) {
 				}},
 			},
 		},
+		{
+			name: "primary key tag",
+			val: func() any {
+				type PrimaryKeyTag struct {
+					Name string
+					Code string `morm:"primary_key=true"`
+				}
+				return &PrimaryKeyTag{}
+			}(),
+			wantModel: func() *Model {
+				name := &Field{
+					ColName: "name",
+					Type:    reflect.TypeOf(""),
+					GoName:  "Name",
+				}
+				code := &Field{
+					ColName: "code",
+					Type:    reflect.TypeOf(""),
+					GoName:  "Code",
+					Offset:  16,
+					Index:   1,
+				}
+				return &Model{
+					TableName:  "primary_key_tag",
+					FieldMap:   map[string]*Field{"Name": name, "Code": code},
+					ColumnMap:  map[string]*Field{"name": name, "code": code},
+					Fields:     []*Field{name, code},
+					PrimaryKey: code,
+				}
+			}(),
+		},
+		{
+			// 显式关闭自增
+			name: "auto increment tag",
+			val: func() any {
+				type AutoIncrementTag struct {
+					Id int64 `morm:"auto_increment=false"`
+				}
+				return &AutoIncrementTag{}
+			}(),
+			wantModel: func() *Model {
+				id := &Field{
+					ColName: "id",
+					Type:    reflect.TypeOf(int64(0)),
+					GoName:  "Id",
+				}
+				return &Model{
+					TableName:  "auto_increment_tag",
+					FieldMap:   map[string]*Field{"Id": id},
+					ColumnMap:  map[string]*Field{"id": id},
+					Fields:     []*Field{id},
+					PrimaryKey: id,
+				}
+			}(),
+		},
+		{
+			// 显式声明 Id 不是主键
+			name: "not primary key",
+			val: func() any {
+				type NotPrimaryKey struct {
+					Id int64 `morm:"primary_key=false"`
+				}
+				return &NotPrimaryKey{}
+			}(),
+			wantModel: func() *Model {
+				id := &Field{
+					ColName: "id",
+					Type:    reflect.TypeOf(int64(0)),
+					GoName:  "Id",
+				}
+				return &Model{
+					TableName: "not_primary_key",
+					FieldMap:  map[string]*Field{"Id": id},
+					ColumnMap: map[string]*Field{"id": id},
+					Fields:    []*Field{id},
+				}
+			}(),
+		},
+		{
+			name: "invalid primary key tag",
+			val: func() any {
+				type InvalidPrimaryKeyTag struct {
+					Id int64 `morm:"primary_key=abc"`
+				}
+				return &InvalidPrimaryKeyTag{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("primary_key=abc"),
+		},
+		{
+			name: "multiple primary keys",
+			val: func() any {
+				type MultiplePrimaryKeys struct {
+					Id   int64 `morm:"primary_key=true"`
+					Code int64 `morm:"primary_key=true"`
+				}
+				return &MultiplePrimaryKeys{}
+			}(),
+			wantErr: errs.NewErrMultiplePrimaryKeys("Id", "Code"),
+		},
 		// interface test
 		{
 			name: "custom table name",
@@ -156,8 +156,8 @@ func memoryDB(t *testing.T, opts ...DBOption) *DB {
 	return orm
 }
 
-func memoryDBWithDB(db string, t *testing.T) *DB {
-	orm, err := Open("sqlite3", fmt.Sprintf("file:%s.db?cache=shared&mode=memory", db))
+func memoryDBWithDB(db string, t *testing.T, opts ...DBOption) *DB {
+	orm, err := Open("sqlite3", fmt.Sprintf("file:%s.db?cache=shared&mode=memory", db), opts...)
 	if err != nil {
 		t.Fatal(err)
 	}
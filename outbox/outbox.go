@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"context"
+	"github.com/NotFound1911/morm"
+	"time"
+)
+
+const (
+	// StatusPending 等待投递
+	StatusPending int8 = iota
+	// StatusSent 已经投递
+	StatusSent
+)
+
+// Message 发件箱中的一条消息，和业务数据在同一个事务中写入
+// 对应的表结构，以 MySQL 为例：
+//
+//	CREATE TABLE outbox_message(
+//	    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+//	    topic VARCHAR(255) NOT NULL,
+//	    payload BLOB NOT NULL,
+//	    status TINYINT NOT NULL,
+//	    created_at BIGINT NOT NULL,
+//	    sent_at BIGINT NOT NULL,
+//	    INDEX idx_status_id(status, id)
+//	)
+type Message struct {
+	Id      int64
+	Topic   string
+	Payload []byte
+	Status  int8
+	// CreatedAt 和 SentAt 都是毫秒数
+	CreatedAt int64
+	SentAt    int64
+}
+
+func (Message) TableName() string {
+	return "outbox_message"
+}
+
+// Publish 在事务中写入一条消息，事务提交之后消息才会被 Relay 投递
+// 用法是 outbox.Publish(ctx, tx, topic, payload)，而不是 tx.Publish(ctx, topic, payload)：
+// Go 不允许在其它包给 morm.Tx 定义方法，而把方法定义在 morm 包里面，
+// 又需要 morm 反过来依赖 outbox 的 Message，形成循环依赖，所以这里是一个接收 tx 的函数
+func Publish(ctx context.Context, tx *morm.Tx, topic string, payload []byte) error {
+	return morm.NewInserter[Message](tx).Values(&Message{
+		Topic:     topic,
+		Payload:   payload,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UnixMilli(),
+	}).Exec(ctx).Err()
+}
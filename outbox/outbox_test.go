@@ -0,0 +1,181 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/NotFound1911/morm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryPublisher 把消息保存在内存中，topic 为 fail 的消息会投递失败
+type memoryPublisher struct {
+	mu   sync.Mutex
+	msgs []*Message
+}
+
+func (p *memoryPublisher) Publish(ctx context.Context, msg *Message) error {
+	if msg.Topic == "fail" {
+		return errors.New("mock publish error")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+func (p *memoryPublisher) payloads() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := make([]string, 0, len(p.msgs))
+	for _, msg := range p.msgs {
+		res = append(res, string(msg.Payload))
+	}
+	return res
+}
+
+type Order struct {
+	Id     int64
+	Amount int64
+}
+
+func newDB(t *testing.T, name string) *morm.DB {
+	sqlDB, err := sql.Open("sqlite3", fmt.Sprintf("file:%s.db?cache=shared&mode=memory", name))
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`
+CREATE TABLE outbox_message(
+    id INTEGER PRIMARY KEY,
+    topic TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    status INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    sent_at INTEGER NOT NULL
+)`)
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`CREATE TABLE ` + "`order`" + `(id INTEGER PRIMARY KEY, amount INTEGER NOT NULL)`)
+	require.NoError(t, err)
+	db, err := morm.OpenDB(sqlDB, morm.DBWithDialect(morm.SQLite3))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// createOrder 在同一个事务中写入订单和消息
+func createOrder(ctx context.Context, db *morm.DB, amount int64, topic string, fail bool) error {
+	return db.DoTx(ctx, func(ctx context.Context, tx *morm.Tx) error {
+		o := &Order{Amount: amount}
+		if err := morm.NewInserter[Order](tx).Values(o).Exec(ctx).Err(); err != nil {
+			return err
+		}
+		if err := Publish(ctx, tx, topic, []byte(fmt.Sprintf("order-%d", amount))); err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("mock business error")
+		}
+		return nil
+	}, nil)
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	db := newDB(t, "outbox_relay_once")
+	ctx := context.Background()
+	require.NoError(t, createOrder(ctx, db, 1, "order", false))
+	require.NoError(t, createOrder(ctx, db, 2, "order", false))
+	// 业务失败，消息也一起回滚
+	require.Error(t, createOrder(ctx, db, 3, "order", true))
+	require.NoError(t, createOrder(ctx, db, 4, "order", false))
+
+	p := &memoryPublisher{}
+	relay := NewRelay(db, p, RelayWithBatchSize(2))
+	n, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"order-1", "order-2"}, p.payloads())
+
+	n, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"order-1", "order-2", "order-4"}, p.payloads())
+
+	n, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	msgs, err := morm.NewSelector[Message](db).OrderBy(morm.Asc("Id")).GetMulti(ctx)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	for _, msg := range msgs {
+		assert.Equal(t, StatusSent, msg.Status)
+		assert.True(t, msg.SentAt >= msg.CreatedAt)
+	}
+
+	orders, err := morm.NewSelector[Order](db).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Len(t, orders, 3)
+}
+
+func TestRelay_PublishFailed(t *testing.T) {
+	db := newDB(t, "outbox_publish_failed")
+	ctx := context.Background()
+	require.NoError(t, createOrder(ctx, db, 1, "order", false))
+	require.NoError(t, createOrder(ctx, db, 2, "fail", false))
+	require.NoError(t, createOrder(ctx, db, 3, "order", false))
+
+	p := &memoryPublisher{}
+	relay := NewRelay(db, p)
+	// 投递失败的消息之前的消息仍然会被标记为已投递，之后的消息保持顺序，下次再投递
+	n, err := relay.RelayOnce(ctx)
+	assert.Equal(t, errors.New("mock publish error"), err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"order-1"}, p.payloads())
+
+	pending, err := morm.NewSelector[Message](db).
+		Where(morm.C("Status").EQ(StatusPending)).OrderBy(morm.Asc("Id")).GetMulti(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "fail", pending[0].Topic)
+}
+
+func TestRelay_Run(t *testing.T) {
+	db := newDB(t, "outbox_run")
+	for i := int64(1); i <= 5; i++ {
+		require.NoError(t, createOrder(context.Background(), db, i, "order", false))
+	}
+	p := &memoryPublisher{}
+	relay := NewRelay(db, p, RelayWithBatchSize(2), RelayWithInterval(time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- relay.Run(ctx)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(p.payloads()) == 5
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, []string{"order-1", "order-2", "order-3", "order-4", "order-5"}, p.payloads())
+}
+
+func TestRelay_InvalidBatchSize(t *testing.T) {
+	db := newDB(t, "outbox_batch_size")
+	for i := int64(1); i <= 3; i++ {
+		require.NoError(t, createOrder(context.Background(), db, i, "order", false))
+	}
+	p := &memoryPublisher{}
+	relay := NewRelay(db, p, RelayWithBatchSize(0))
+	assert.Equal(t, 1, relay.batchSize)
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"order-1"}, p.payloads())
+
+	relay = NewRelay(db, p, RelayWithBatchSize(-1))
+	assert.Equal(t, 1, relay.batchSize)
+}
@@ -0,0 +1,136 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/NotFound1911/morm"
+	"log"
+	"time"
+)
+
+// Publisher 将消息投递到消息队列，例如 Kafka
+// 返回 nil 代表投递成功，消息会被标记为已投递
+// Relay 只保证至少投递一次，所以消费者需要自己处理重复的消息
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+type RelayOption func(r *Relay)
+
+// Relay 轮询发件箱，将还没有投递的消息交给 Publisher
+// 使用 FOR UPDATE SKIP LOCKED 锁住一批消息，所以可以部署多个 Relay 同时投递
+type Relay struct {
+	db        *morm.DB
+	publisher Publisher
+	batchSize int
+	interval  time.Duration
+	errFunc   func(ctx context.Context, err error)
+}
+
+func NewRelay(db *morm.DB, publisher Publisher, opts ...RelayOption) *Relay {
+	res := &Relay{
+		db:        db,
+		publisher: publisher,
+		batchSize: 100,
+		interval:  time.Second,
+		errFunc: func(ctx context.Context, err error) {
+			log.Println(err)
+		},
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	// batchSize 为 0 的时候 Limit 不生效，并且 Run 会认为每一批都是满的，不停地轮询数据库
+	if res.batchSize < 1 {
+		res.batchSize = 1
+	}
+	return res
+}
+
+// RelayWithBatchSize 每次最多投递的消息数量，小于 1 的时候使用 1
+func RelayWithBatchSize(n int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// RelayWithInterval 没有消息的时候，下一次轮询之前等待的时间
+func RelayWithInterval(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = d
+	}
+}
+
+// RelayWithErrorHandler 处理 Run 过程中的错误，默认输出日志
+func RelayWithErrorHandler(fn func(ctx context.Context, err error)) RelayOption {
+	return func(r *Relay) {
+		r.errFunc = fn
+	}
+}
+
+// RelayOnce 投递一批消息，返回投递成功的数量
+// 按照写入的顺序投递，遇到投递失败的消息就停下来，已经投递成功的消息仍然会被标记为已投递
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	var (
+		sent       int
+		publishErr error
+	)
+	err := r.db.DoTx(ctx, func(ctx context.Context, tx *morm.Tx) error {
+		msgs, err := morm.NewSelector[Message](tx).
+			Where(morm.C("Status").EQ(StatusPending)).
+			OrderBy(morm.Asc("Id")).
+			Limit(r.batchSize).
+			ForUpdate(morm.SkipLocked()).
+			GetMulti(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ids := make([]any, 0, len(msgs))
+		for _, msg := range msgs {
+			if publishErr = r.publisher.Publish(ctx, msg); publishErr != nil {
+				break
+			}
+			ids = append(ids, msg.Id)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		err = morm.NewUpdater[Message](tx).
+			Update(&Message{Status: StatusSent, SentAt: time.Now().UnixMilli()}).
+			Set(morm.C("Status"), morm.C("SentAt")).
+			Where(morm.C("Id").In(ids...)).
+			Exec(ctx).Err()
+		if err != nil {
+			return err
+		}
+		sent = len(ids)
+		return nil
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return sent, publishErr
+}
+
+// Run 不断地投递消息，直到 ctx 被取消
+// 一批消息满了说明可能还有消息，所以立刻开始下一次投递，否则等待 interval
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil {
+			r.errFunc(ctx, err)
+		}
+		if err == nil && n >= r.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
@@ -4,16 +4,21 @@ package morm
 type opt string
 
 const (
-	optEQ    = "="
-	optLT    = "<"
-	optGT    = ">"
-	optAND   = "AND"
-	optOR    = "OR"
-	optNOT   = "NOT"
-	optADD   = "+"
-	optMULTI = "*"
-	optIN    = "IN"
-	optEXIST = "EXIST"
+	optEQ        = "="
+	optLT        = "<"
+	optGT        = ">"
+	optNEQ       = "!="
+	optLTEQ      = "<="
+	optGTEQ      = ">="
+	optAND       = "AND"
+	optOR        = "OR"
+	optNOT       = "NOT"
+	optADD       = "+"
+	optMULTI     = "*"
+	optIN        = "IN"
+	optIsNull    = "IS NULL"
+	optIsNotNull = "IS NOT NULL"
+	optEXIST     = "EXIST"
 )
 
 func (o opt) String() string {
@@ -0,0 +1,100 @@
+package morm
+
+import (
+	"context"
+	"github.com/NotFound1911/morm/errors"
+)
+
+// Propagation 事务传播行为，决定 DoTxCtx 在已经存在事务的时候怎么处理
+type Propagation int
+
+const (
+	// PropagationRequired 已经存在事务就加入，否则开启新事务
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew 总是开启新事务，和已经存在的事务互不影响
+	PropagationRequiresNew
+	// PropagationNested 已经存在事务就创建保存点，否则开启新事务
+	PropagationNested
+	// PropagationSupports 已经存在事务就加入，否则不使用事务
+	PropagationSupports
+	// PropagationNever 不使用事务，已经存在事务就返回错误
+	PropagationNever
+	// PropagationMandatory 必须加入已经存在的事务，否则返回错误
+	PropagationMandatory
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagationRequired:
+		return "REQUIRED"
+	case PropagationRequiresNew:
+		return "REQUIRES_NEW"
+	case PropagationNested:
+		return "NESTED"
+	case PropagationSupports:
+		return "SUPPORTS"
+	case PropagationNever:
+		return "NEVER"
+	case PropagationMandatory:
+		return "MANDATORY"
+	}
+	return "UNKNOWN"
+}
+
+// txKey 每个 DB 在 context 里面有自己的事务，互不干扰
+type txKey struct {
+	db *DB
+}
+
+// withTx 将事务放入 context，之后通过 db 创建的查询都会在这个事务中执行
+func withTx(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txKey{db: tx.db}, tx)
+}
+
+// TxFromContext 获得 context 中 db 正在使用的事务
+func (db *DB) TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txKey{db: db}).(*Tx)
+	return tx, ok
+}
+
+// DoTxCtx 按照传播行为执行 fn，事务保存在 context 中
+// 在 fn 里面用 db 创建的 Selector、Inserter 等，只要执行的时候传入 fn 的 ctx，就会自动使用这个事务
+func (db *DB) DoTxCtx(ctx context.Context, fn func(ctx context.Context) error, p Propagation) error {
+	tx, inTx := db.TxFromContext(ctx)
+	switch p {
+	case PropagationRequired:
+		if inTx {
+			return fn(ctx)
+		}
+		return db.doTxCtx(ctx, fn)
+	case PropagationRequiresNew:
+		return db.doTxCtx(ctx, fn)
+	case PropagationNested:
+		if inTx {
+			return tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+				return fn(ctx)
+			}, nil)
+		}
+		return db.doTxCtx(ctx, fn)
+	case PropagationSupports:
+		return fn(ctx)
+	case PropagationNever:
+		if inTx {
+			return errs.NewErrTxPropagation(p.String(), "已经存在事务")
+		}
+		return fn(ctx)
+	case PropagationMandatory:
+		if !inTx {
+			return errs.NewErrTxPropagation(p.String(), "不存在事务")
+		}
+		return fn(ctx)
+	}
+	return errs.NewErrTxPropagation(p.String(), "未知的传播行为")
+}
+
+// doTxCtx 开启新的事务，并且覆盖 context 中原有的事务
+func (db *DB) doTxCtx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		return fn(withTx(ctx, tx))
+	}, nil)
+}
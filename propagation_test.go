@@ -0,0 +1,248 @@
+package morm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDB_DoTxCtx(t *testing.T) {
+	mockErr := errors.New("mock error")
+	insert := func(db *DB) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			return NewInserter[TestModel](db).Values(&TestModel{}).Exec(ctx).Err()
+		}
+	}
+	testCases := []struct {
+		name    string
+		mock    func(mock sqlmock.Sqlmock)
+		fn      func(db *DB) func(ctx context.Context) error
+		p       Propagation
+		wantErr error
+	}{
+		{
+			name: "required without tx",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: insert,
+			p:  PropagationRequired,
+		},
+		{
+			name: "required join",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insert(db)(ctx); err != nil {
+						return err
+					}
+					return db.DoTxCtx(ctx, insert(db), PropagationRequired)
+				}
+			},
+			p: PropagationRequired,
+		},
+		{
+			name: "required join error",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return db.DoTxCtx(ctx, func(ctx context.Context) error {
+						return mockErr
+					}, PropagationRequired)
+				}
+			},
+			p:       PropagationRequired,
+			wantErr: mockErr,
+		},
+		{
+			name: "requires new",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+				mock.ExpectRollback()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					// 内层的事务已经提交，不受外层回滚的影响
+					if err := db.DoTxCtx(ctx, insert(db), PropagationRequiresNew); err != nil {
+						return err
+					}
+					return mockErr
+				}
+			},
+			p:       PropagationRequired,
+			wantErr: mockErr,
+		},
+		{
+			name: "nested",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					err := db.DoTxCtx(ctx, func(ctx context.Context) error {
+						if err := insert(db)(ctx); err != nil {
+							return err
+						}
+						return mockErr
+					}, PropagationNested)
+					if err != mockErr {
+						return errors.New("want mock error")
+					}
+					return insert(db)(ctx)
+				}
+			},
+			p: PropagationRequired,
+		},
+		{
+			name: "nested without tx",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: insert,
+			p:  PropagationNested,
+		},
+		{
+			name: "supports without tx",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			fn: insert,
+			p:  PropagationSupports,
+		},
+		{
+			name: "supports join",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return db.DoTxCtx(ctx, insert(db), PropagationSupports)
+				}
+			},
+			p: PropagationRequired,
+		},
+		{
+			name: "never",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			fn: insert,
+			p:  PropagationNever,
+		},
+		{
+			name: "never in tx",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return db.DoTxCtx(ctx, insert(db), PropagationNever)
+				}
+			},
+			p:       PropagationRequired,
+			wantErr: errs.NewErrTxPropagation("NEVER", "已经存在事务"),
+		},
+		{
+			name: "mandatory",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return db.DoTxCtx(ctx, insert(db), PropagationMandatory)
+				}
+			},
+			p: PropagationRequired,
+		},
+		{
+			name:    "mandatory without tx",
+			mock:    func(mock sqlmock.Sqlmock) {},
+			fn:      insert,
+			p:       PropagationMandatory,
+			wantErr: errs.NewErrTxPropagation("MANDATORY", "不存在事务"),
+		},
+		{
+			name: "lock in context tx",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT .* FOR UPDATE;").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectCommit()
+			},
+			fn: func(db *DB) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					_, err := NewSelector[TestModel](db).ForUpdate().Get(ctx)
+					return err
+				}
+			},
+			p: PropagationRequired,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB)
+			require.NoError(t, err)
+			tc.mock(mock)
+
+			err = db.DoTxCtx(context.Background(), tc.fn(db), tc.p)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDB_TxFromContext(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db1, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	db2, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	err = db1.DoTxCtx(context.Background(), func(ctx context.Context) error {
+		tx, ok := db1.TxFromContext(ctx)
+		assert.True(t, ok)
+		assert.NotNil(t, tx)
+		// 其它 DB 看不到这个事务
+		_, ok = db2.TxFromContext(ctx)
+		assert.False(t, ok)
+		return nil
+	}, PropagationRequired)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,308 @@
+package queryparam
+
+import (
+	"github.com/NotFound1911/morm"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/NotFound1911/morm/model"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的查询参数：
+//   - filter[col]=val 或者 filter[col][op]=val，op 可以是 eq, ne, gt, gte, lt, lte, in, null
+//     in 的值用逗号分隔，null 的值是 true 或者 false
+//   - sort=-created_at,name，- 代表降序
+//   - page=2&page_size=20，page 从 1 开始
+//
+// 参数中使用的是列名，只有允许的列才可以用于过滤和排序
+const (
+	paramSort     = "sort"
+	paramPage     = "page"
+	paramPageSize = "page_size"
+)
+
+// maxOffset 允许的最大偏移量
+const maxOffset = math.MaxInt32
+
+const (
+	defaultPageSize    = 20
+	defaultMaxPageSize = 100
+)
+
+const (
+	opEQ  = "eq"
+	opNE  = "ne"
+	opGT  = "gt"
+	opGTE = "gte"
+	opLT  = "lt"
+	opLTE = "lte"
+	opIN  = "in"
+	opNil = "null"
+)
+
+var filterKey = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([^\[\]]+)\])?$`)
+
+type Option func(p *Parser)
+
+// Parser 将 url.Values 解析为查询条件、排序和分页
+type Parser struct {
+	model      *model.Model
+	filterable map[string]struct{}
+	sortable   map[string]struct{}
+
+	defaultPageSize int
+	maxPageSize     int
+}
+
+// Result 解析结果
+type Result struct {
+	Predicates []morm.Predicate
+	OrderBys   []morm.OrderBy
+	Limit      int
+	Offset     int
+}
+
+// NewParser 创建一个 Parser，默认不允许任何列用于过滤和排序
+func NewParser(m *model.Model, opts ...Option) *Parser {
+	res := &Parser{
+		model:           m,
+		filterable:      map[string]struct{}{},
+		sortable:        map[string]struct{}{},
+		defaultPageSize: defaultPageSize,
+		maxPageSize:     defaultMaxPageSize,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	// 分页大小必须是正数，不然计算偏移量的时候会除以 0
+	if res.defaultPageSize <= 0 {
+		res.defaultPageSize = defaultPageSize
+	}
+	if res.maxPageSize <= 0 {
+		res.maxPageSize = defaultMaxPageSize
+	}
+	return res
+}
+
+// WithFilterable 允许用于过滤的列
+func WithFilterable(cols ...string) Option {
+	return func(p *Parser) {
+		for _, col := range cols {
+			p.filterable[col] = struct{}{}
+		}
+	}
+}
+
+// WithSortable 允许用于排序的列
+func WithSortable(cols ...string) Option {
+	return func(p *Parser) {
+		for _, col := range cols {
+			p.sortable[col] = struct{}{}
+		}
+	}
+}
+
+// WithPageSize 默认的分页大小和最大的分页大小，超过最大值的时候使用最大值
+// 小于等于 0 的值会被忽略，使用默认的 20 和 100
+func WithPageSize(defaultSize, maxSize int) Option {
+	return func(p *Parser) {
+		p.defaultPageSize = defaultSize
+		p.maxPageSize = maxSize
+	}
+}
+
+func (p *Parser) Parse(vals url.Values) (*Result, error) {
+	res := &Result{}
+	// 按照参数名排序，保证生成的 SQL 是稳定的
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		matches := filterKey.FindStringSubmatch(k)
+		if matches == nil {
+			continue
+		}
+		op := matches[2]
+		if op == "" {
+			op = opEQ
+		}
+		for _, val := range vals[k] {
+			pred, err := p.parseFilter(k, matches[1], op, val)
+			if err != nil {
+				return nil, err
+			}
+			res.Predicates = append(res.Predicates, pred)
+		}
+	}
+	orderBys, err := p.parseSort(vals.Get(paramSort))
+	if err != nil {
+		return nil, err
+	}
+	res.OrderBys = orderBys
+	if err = p.parsePage(vals, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p *Parser) parseFilter(param, col, op, val string) (morm.Predicate, error) {
+	fd, err := p.field(p.filterable, col)
+	if err != nil {
+		return morm.Predicate{}, err
+	}
+	c := morm.C(fd.GoName)
+	switch op {
+	case opNil:
+		isNull, err := strconv.ParseBool(val)
+		if err != nil {
+			return morm.Predicate{}, errs.NewErrInvalidQueryParam(param, "需要 true 或者 false")
+		}
+		if isNull {
+			return c.IsNull(), nil
+		}
+		return c.IsNotNull(), nil
+	case opIN:
+		parts := strings.Split(val, ",")
+		args := make([]any, 0, len(parts))
+		for _, part := range parts {
+			arg, err := convert(fd.Type, part)
+			if err != nil {
+				return morm.Predicate{}, errs.NewErrInvalidQueryParam(param, err.Error())
+			}
+			args = append(args, arg)
+		}
+		return c.In(args...), nil
+	}
+	arg, err := convert(fd.Type, val)
+	if err != nil {
+		return morm.Predicate{}, errs.NewErrInvalidQueryParam(param, err.Error())
+	}
+	switch op {
+	case opEQ:
+		return c.EQ(arg), nil
+	case opNE:
+		return c.NEQ(arg), nil
+	case opGT:
+		return c.GT(arg), nil
+	case opGTE:
+		return c.GTEQ(arg), nil
+	case opLT:
+		return c.LT(arg), nil
+	case opLTE:
+		return c.LTEQ(arg), nil
+	default:
+		return morm.Predicate{}, errs.NewErrInvalidQueryParam(param, "不支持的操作符 "+op)
+	}
+}
+
+func (p *Parser) parseSort(val string) ([]morm.OrderBy, error) {
+	if val == "" {
+		return nil, nil
+	}
+	cols := strings.Split(val, ",")
+	res := make([]morm.OrderBy, 0, len(cols))
+	for _, col := range cols {
+		desc := strings.HasPrefix(col, "-")
+		col = strings.TrimPrefix(col, "-")
+		fd, err := p.field(p.sortable, col)
+		if err != nil {
+			return nil, err
+		}
+		if desc {
+			res = append(res, morm.Desc(fd.GoName))
+		} else {
+			res = append(res, morm.Asc(fd.GoName))
+		}
+	}
+	return res, nil
+}
+
+func (p *Parser) parsePage(vals url.Values, res *Result) error {
+	page, err := positiveInt(vals, paramPage, 1)
+	if err != nil {
+		return err
+	}
+	size, err := positiveInt(vals, paramPageSize, p.defaultPageSize)
+	if err != nil {
+		return err
+	}
+	if size > p.maxPageSize {
+		size = p.maxPageSize
+	}
+	// 避免 (page - 1) * size 溢出
+	if page-1 > maxOffset/size {
+		return errs.NewErrInvalidQueryParam(paramPage, "页码过大")
+	}
+	res.Limit = size
+	res.Offset = (page - 1) * size
+	return nil
+}
+
+// field 检查列是否在允许的范围内，并且找到对应的字段
+func (p *Parser) field(allowed map[string]struct{}, col string) (*model.Field, error) {
+	if _, ok := allowed[col]; !ok {
+		return nil, errs.NewErrFieldNotAllowed(col)
+	}
+	fd, ok := p.model.ColumnMap[col]
+	if !ok {
+		return nil, errs.NewErrUnknownField(col)
+	}
+	return fd, nil
+}
+
+func positiveInt(vals url.Values, param string, defaultVal int) (int, error) {
+	val := vals.Get(param)
+	if val == "" {
+		return defaultVal, nil
+	}
+	res, err := strconv.Atoi(val)
+	if err != nil || res < 1 {
+		return 0, errs.NewErrInvalidQueryParam(param, "需要正整数")
+	}
+	return res, nil
+}
+
+// convert 按照字段的类型转换参数，其余的类型例如 time.Time 直接使用字符串
+func convert(typ reflect.Type, val string) (any, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		res, err := strconv.ParseInt(val, 10, typ.Bits())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(res).Convert(typ).Interface(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		res, err := strconv.ParseUint(val, 10, typ.Bits())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(res).Convert(typ).Interface(), nil
+	case reflect.Float32, reflect.Float64:
+		res, err := strconv.ParseFloat(val, typ.Bits())
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(res).Convert(typ).Interface(), nil
+	case reflect.Bool:
+		return strconv.ParseBool(val)
+	default:
+		return val, nil
+	}
+}
+
+// Apply 将解析结果应用到 Selector 上
+// 解析出来的条件和 Selector 已有的条件是 AND 的关系，例如调用者限定的租户不会被覆盖；
+// 解析出来的排序追加在已有的排序后面
+func Apply[T any](s *morm.Selector[T], res *Result) *morm.Selector[T] {
+	return s.AndWhere(res.Predicates...).AppendOrderBy(res.OrderBys...).Limit(res.Limit).Offset(res.Offset)
+}
@@ -0,0 +1,218 @@
+package queryparam
+
+import (
+	"database/sql"
+	"github.com/NotFound1911/morm"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/NotFound1911/morm/model"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+)
+
+func TestParser_Parse(t *testing.T) {
+	m, err := model.NewRegistry().Get(&TestModel{})
+	require.NoError(t, err)
+	db, err := morm.Open("sqlite3", "file:test.db?cache=shared&mode=memory")
+	require.NoError(t, err)
+	p := NewParser(m,
+		WithFilterable("id", "age", "first_name", "last_name"),
+		WithSortable("id", "age"),
+		WithPageSize(10, 50))
+	testCases := []struct {
+		name      string
+		query     string
+		wantQuery *morm.Query
+		wantErr   error
+	}{
+		{
+			name:  "empty",
+			query: "",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` LIMIT ?;",
+				Args: []any{10},
+			},
+		},
+		{
+			name:  "eq",
+			query: "filter[first_name]=Tom",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ? LIMIT ?;",
+				Args: []any{"Tom", 10},
+			},
+		},
+		{
+			name:  "operators",
+			query: "filter[age][gte]=18&filter[age][lt]=60&filter[id][in]=1,2&filter[last_name][null]=true",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (((`age` >= ?) AND (`age` < ?)) AND (`id` IN (?,?))) AND (`last_name` IS NULL) LIMIT ?;",
+				Args: []any{int8(18), int8(60), int64(1), int64(2), 10},
+			},
+		},
+		{
+			name:  "ne lte not null",
+			query: "filter[age][lte]=18&filter[first_name][ne]=Tom&filter[last_name][null]=false",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`age` <= ?) AND (`first_name` != ?)) AND (`last_name` IS NOT NULL) LIMIT ?;",
+				Args: []any{int8(18), "Tom", 10},
+			},
+		},
+		{
+			name:  "sort and page",
+			query: "sort=-age,id&page=3&page_size=20",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` ORDER BY `age` DESC,`id` ASC LIMIT ? OFFSET ?;",
+				Args: []any{20, 40},
+			},
+		},
+		{
+			name:  "max page size",
+			query: "page_size=1000",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` LIMIT ?;",
+				Args: []any{50},
+			},
+		},
+		{
+			name:  "ignore other params",
+			query: "q=abc&filter=1",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` LIMIT ?;",
+				Args: []any{10},
+			},
+		},
+		{
+			name:    "filter not allowed",
+			query:   "filter[password]=123",
+			wantErr: errs.NewErrFieldNotAllowed("password"),
+		},
+		{
+			name:    "sort not allowed",
+			query:   "sort=first_name",
+			wantErr: errs.NewErrFieldNotAllowed("first_name"),
+		},
+		{
+			name:    "invalid operator",
+			query:   "filter[age][like]=1",
+			wantErr: errs.NewErrInvalidQueryParam("filter[age][like]", "不支持的操作符 like"),
+		},
+		{
+			name:    "invalid value",
+			query:   "filter[age][gt]=abc",
+			wantErr: errs.NewErrInvalidQueryParam("filter[age][gt]", `strconv.ParseInt: parsing "abc": invalid syntax`),
+		},
+		{
+			name:    "invalid null",
+			query:   "filter[last_name][null]=abc",
+			wantErr: errs.NewErrInvalidQueryParam("filter[last_name][null]", "需要 true 或者 false"),
+		},
+		{
+			name:    "invalid page",
+			query:   "page=0",
+			wantErr: errs.NewErrInvalidQueryParam("page", "需要正整数"),
+		},
+		{
+			name:    "page overflow",
+			query:   "page=9223372036854775807&page_size=50",
+			wantErr: errs.NewErrInvalidQueryParam("page", "页码过大"),
+		},
+		{
+			name:  "max page",
+			query: "page=42949673&page_size=50",
+			wantQuery: &morm.Query{
+				SQL:  "SELECT * FROM `test_model` LIMIT ? OFFSET ?;",
+				Args: []any{50, 2147483600},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vals, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			res, err := p.Parse(vals)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			q, err := Apply(morm.NewSelector[TestModel](db), res).Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	m, err := model.NewRegistry().Get(&TestModel{})
+	require.NoError(t, err)
+	db, err := morm.Open("sqlite3", "file:test.db?cache=shared&mode=memory")
+	require.NoError(t, err)
+	p := NewParser(m, WithFilterable("id", "age"), WithSortable("age"))
+	vals, err := url.ParseQuery("filter[id]=2&sort=-age")
+	require.NoError(t, err)
+	res, err := p.Parse(vals)
+	require.NoError(t, err)
+
+	// 调用者的条件不会被覆盖，调用者的排序在前面
+	s := morm.NewSelector[TestModel](db).Where(morm.C("FirstName").EQ("tenant")).OrderBy(morm.Asc("Id"))
+	q, err := Apply(s, res).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &morm.Query{
+		SQL:  "SELECT * FROM `test_model` WHERE (`first_name` = ?) AND (`id` = ?) ORDER BY `id` ASC,`age` DESC LIMIT ?;",
+		Args: []any{"tenant", int64(2), 20},
+	}, q)
+}
+
+func TestParser_InvalidPageSize(t *testing.T) {
+	m, err := model.NewRegistry().Get(&TestModel{})
+	require.NoError(t, err)
+	testCases := []struct {
+		name       string
+		p          *Parser
+		query      string
+		wantResult *Result
+	}{
+		{
+			name:       "zero default",
+			p:          NewParser(m, WithPageSize(0, 100)),
+			wantResult: &Result{Limit: 20},
+		},
+		{
+			name:       "zero max",
+			p:          NewParser(m, WithPageSize(10, 0)),
+			query:      "page=2&page_size=1000",
+			wantResult: &Result{Limit: 100, Offset: 100},
+		},
+		{
+			name:       "negative",
+			p:          NewParser(m, WithPageSize(-1, -1)),
+			query:      "page=3",
+			wantResult: &Result{Limit: 20, Offset: 40},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vals, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			res, err := tc.p.Parse(vals)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantResult, res)
+		})
+	}
+}
+
+func TestParser_UnknownColumn(t *testing.T) {
+	m, err := model.NewRegistry().Get(&TestModel{})
+	require.NoError(t, err)
+	p := NewParser(m, WithFilterable("password"))
+	_, err = p.Parse(url.Values{"filter[password]": []string{"123"}})
+	assert.Equal(t, errs.NewErrUnknownField("password"), err)
+}
+
+type TestModel struct {
+	Id        int64
+	FirstName string
+	Age       int8
+	LastName  *sql.NullString
+}
@@ -0,0 +1,198 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica 只读副本
+type Replica struct {
+	DB *sql.DB
+	// Weight 权重，只有 Weighted 负载均衡策略会使用，小于等于 0 的时候当做 1
+	Weight int
+}
+
+// DBWithReplicas 读写分离，查询会发送到只读副本上，
+// 而写操作、事务、UsePrimary 标记的查询会发送到主库上
+func DBWithReplicas(b Balancer, replicas ...Replica) DBOption {
+	return func(db *DB) error {
+		rs := make([]*replica, 0, len(replicas))
+		for _, r := range replicas {
+			weight := r.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			rs = append(rs, &replica{db: r.DB, weight: weight})
+		}
+		db.replicas = &replicaGroup{
+			replicas: rs,
+			balancer: b,
+			cooldown: defaultReplicaCooldown,
+		}
+		return nil
+	}
+}
+
+type primaryKey struct{}
+
+// UsePrimary 标记查询要发送到主库，例如刚写入的数据需要立刻读出来
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func isPrimary(ctx context.Context) bool {
+	val, _ := ctx.Value(primaryKey{}).(bool)
+	return val
+}
+
+// defaultReplicaCooldown 副本被标记为不可用之后，经过这段时间会再次尝试
+const defaultReplicaCooldown = 10 * time.Second
+
+type replica struct {
+	db     *sql.DB
+	weight int
+	// inFlight 正在执行的查询数量，只统计到查询返回为止，不包括读取结果的过程
+	inFlight atomic.Int64
+	// downUntil 不可用的截止时间，UnixNano
+	downUntil atomic.Int64
+}
+
+func (r *replica) healthy(now time.Time) bool {
+	return r.downUntil.Load() <= now.UnixNano()
+}
+
+type replicaGroup struct {
+	replicas []*replica
+	balancer Balancer
+	cooldown time.Duration
+}
+
+// healthy 返回可用的副本
+func (g *replicaGroup) healthy() []*replica {
+	now := time.Now()
+	res := make([]*replica, 0, len(g.replicas))
+	for _, r := range g.replicas {
+		if r.healthy(now) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// queryContext 在副本上执行查询，副本连接失败的时候会标记为不可用并且换一个副本，
+// 所有副本都不可用的时候返回 ok = false，由调用者使用主库
+func (g *replicaGroup) queryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, ok bool, err error) {
+	for {
+		rs := g.healthy()
+		if len(rs) == 0 {
+			return nil, false, nil
+		}
+		r := g.balancer.pick(rs)
+		r.inFlight.Add(1)
+		rows, err = r.db.QueryContext(ctx, query, args...)
+		r.inFlight.Add(-1)
+		// 调用者的 context 超时或者取消，不是副本的问题，也不应该再换一个副本
+		if err == nil || ctx.Err() != nil || !isConnErr(err) {
+			return rows, true, err
+		}
+		r.downUntil.Store(time.Now().Add(g.cooldown).UnixNano())
+	}
+}
+
+// isConnErr 判断是不是连接层面的错误，SQL 本身的错误不影响副本的健康状态
+// context.DeadlineExceeded 也实现了 net.Error，所以要先排除
+func isConnErr(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Balancer 副本的负载均衡策略
+type Balancer interface {
+	// pick 从可用的副本中选择一个，replicas 不会为空
+	pick(replicas []*replica) *replica
+}
+
+// RoundRobin 轮询
+func RoundRobin() Balancer {
+	return &roundRobinBalancer{}
+}
+
+// Random 随机
+func Random() Balancer {
+	return &randomBalancer{}
+}
+
+// Weighted 平滑加权轮询，权重由 Replica.Weight 指定
+func Weighted() Balancer {
+	return &weightedBalancer{current: map[*replica]int{}}
+}
+
+// LeastInFlight 选择正在执行的查询最少的副本
+func LeastInFlight() Balancer {
+	return leastInFlightBalancer{}
+}
+
+type roundRobinBalancer struct {
+	cnt atomic.Uint64
+}
+
+func (b *roundRobinBalancer) pick(replicas []*replica) *replica {
+	idx := b.cnt.Add(1) - 1
+	return replicas[idx%uint64(len(replicas))]
+}
+
+type randomBalancer struct{}
+
+func (b *randomBalancer) pick(replicas []*replica) *replica {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+type weightedBalancer struct {
+	mutex   sync.Mutex
+	current map[*replica]int
+}
+
+// pick 和 Nginx 一样，每次给所有副本加上自身的权重，选择当前权重最大的，
+// 被选中的副本减去总权重，这样高权重的副本不会被连续选中
+func (b *weightedBalancer) pick(replicas []*replica) *replica {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var (
+		total int
+		res   *replica
+	)
+	for _, r := range replicas {
+		total += r.weight
+		b.current[r] += r.weight
+		if res == nil || b.current[r] > b.current[res] {
+			res = r
+		}
+	}
+	b.current[res] -= total
+	return res
+}
+
+type leastInFlightBalancer struct{}
+
+func (leastInFlightBalancer) pick(replicas []*replica) *replica {
+	res := replicas[0]
+	for _, r := range replicas[1:] {
+		if r.inFlight.Load() < res.inFlight.Load() {
+			res = r
+		}
+	}
+	return res
+}
@@ -0,0 +1,229 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	return mockDB, mock
+}
+
+func TestDB_Replicas(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	r1, r1Mock := newMockDB(t)
+	r2, r2Mock := newMockDB(t)
+	db, err := OpenDB(primary, DBWithReplicas(RoundRobin(), Replica{DB: r1}, Replica{DB: r2}))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	rows := func(id int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id"}).AddRow(id)
+	}
+	// 轮询副本
+	r1Mock.ExpectQuery("SELECT .*").WillReturnRows(rows(1))
+	r2Mock.ExpectQuery("SELECT .*").WillReturnRows(rows(2))
+	r1Mock.ExpectQuery("SELECT .*").WillReturnRows(rows(3))
+	for _, id := range []int64{1, 2, 3} {
+		res, err := NewSelector[TestModel](db).Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, id, res.Id)
+	}
+
+	// 写操作使用主库
+	primaryMock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	res := NewUpdater[TestModel](db).Set(Assign("Age", 18)).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	// UsePrimary 标记的查询使用主库
+	primaryMock.ExpectQuery("SELECT .*").WillReturnRows(rows(4))
+	tm, err := NewSelector[TestModel](db).UsePrimary().Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), tm.Id)
+	primaryMock.ExpectQuery("SELECT .*").WillReturnRows(rows(5))
+	tms, err := NewSelector[TestModel](db).GetMulti(UsePrimary(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), tms[0].Id)
+
+	// 事务使用主库
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery("SELECT .*").WillReturnRows(rows(6))
+	primaryMock.ExpectCommit()
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		tm, err := NewSelector[TestModel](tx).Get(ctx)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, int64(6), tm.Id)
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, r1Mock.ExpectationsWereMet())
+	assert.NoError(t, r2Mock.ExpectationsWereMet())
+}
+
+func TestDB_Replicas_Health(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	r1, r1Mock := newMockDB(t)
+	r2, r2Mock := newMockDB(t)
+	db, err := OpenDB(primary, DBWithReplicas(RoundRobin(), Replica{DB: r1}, Replica{DB: r2}))
+	require.NoError(t, err)
+	db.replicas.cooldown = time.Hour
+	ctx := context.Background()
+	rows := func(id int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id"}).AddRow(id)
+	}
+	connErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	// r1 连接失败，换成 r2，之后 r1 会被跳过
+	r1Mock.ExpectQuery("SELECT .*").WillReturnError(connErr)
+	r2Mock.ExpectQuery("SELECT .*").WillReturnRows(rows(1))
+	r2Mock.ExpectQuery("SELECT .*").WillReturnRows(rows(2))
+	for _, id := range []int64{1, 2} {
+		res, err := NewSelector[TestModel](db).Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, id, res.Id)
+	}
+
+	// SQL 本身的错误不影响健康状态
+	sqlErr := errors.New("syntax error")
+	r2Mock.ExpectQuery("SELECT .*").WillReturnError(sqlErr)
+	_, err = NewSelector[TestModel](db).Get(ctx)
+	assert.Equal(t, sqlErr, err)
+
+	// 所有副本都不可用，使用主库
+	r2Mock.ExpectQuery("SELECT .*").WillReturnError(connErr)
+	primaryMock.ExpectQuery("SELECT .*").WillReturnRows(rows(3))
+	res, err := NewSelector[TestModel](db).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), res.Id)
+
+	// 冷却时间过去之后恢复
+	for _, r := range db.replicas.replicas {
+		r.downUntil.Store(0)
+	}
+	assert.Len(t, db.replicas.healthy(), 2)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, r1Mock.ExpectationsWereMet())
+	assert.NoError(t, r2Mock.ExpectationsWereMet())
+}
+
+func TestDB_Replicas_ContextDone(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	r1, r1Mock := newMockDB(t)
+	r2, r2Mock := newMockDB(t)
+	db, err := OpenDB(primary, DBWithReplicas(RoundRobin(), Replica{DB: r1}, Replica{DB: r2}))
+	require.NoError(t, err)
+	db.replicas.cooldown = time.Hour
+
+	// 调用者超时，副本仍然是健康的，也不会换成其它副本或者主库重试
+	r1Mock.ExpectQuery("SELECT .*").WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = NewSelector[TestModel](db).Get(ctx)
+	assert.Error(t, err)
+	assert.Len(t, db.replicas.healthy(), 2)
+
+	// 已经取消的 context 同样不影响副本
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = NewSelector[TestModel](db).Get(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, db.replicas.healthy(), 2)
+
+	assert.False(t, isConnErr(context.DeadlineExceeded))
+	assert.False(t, isConnErr(context.Canceled))
+	assert.True(t, isConnErr(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, r2Mock.ExpectationsWereMet())
+}
+
+func TestDB_Replicas_Returning(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	r1, r1Mock := newMockDB(t)
+	db, err := OpenDB(primary, DBWithDialect(SQLite3), DBWithReplicas(RoundRobin(), Replica{DB: r1}))
+	require.NoError(t, err)
+
+	// INSERT ... RETURNING 虽然是查询，但是要在主库上执行
+	primaryMock.ExpectQuery("INSERT .* RETURNING `id`;").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	vals := []*TestModel{{FirstName: "a"}, {FirstName: "b"}}
+	res := NewInserter[TestModel](db).Values(vals...).Exec(context.Background())
+	require.NoError(t, res.Err())
+	assert.Equal(t, int64(1), vals[0].Id)
+	assert.Equal(t, int64(2), vals[1].Id)
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, r1Mock.ExpectationsWereMet())
+}
+
+func TestBalancer(t *testing.T) {
+	a := &replica{weight: 5}
+	b := &replica{weight: 1}
+	c := &replica{weight: 1}
+	replicas := []*replica{a, b, c}
+	testCases := []struct {
+		name     string
+		balancer Balancer
+		before   func()
+		// want 选中的副本的下标
+		want []int
+	}{
+		{
+			name:     "round robin",
+			balancer: RoundRobin(),
+			want:     []int{0, 1, 2, 0, 1},
+		},
+		{
+			name:     "weighted",
+			balancer: Weighted(),
+			want:     []int{0, 0, 1, 0, 2, 0, 0, 0, 0, 1},
+		},
+		{
+			name:     "least in flight",
+			balancer: LeastInFlight(),
+			before: func() {
+				a.inFlight.Store(3)
+				b.inFlight.Store(1)
+				c.inFlight.Store(2)
+			},
+			want: []int{1, 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.before != nil {
+				tc.before()
+			}
+			got := make([]int, 0, len(tc.want))
+			for range tc.want {
+				r := tc.balancer.pick(replicas)
+				for i := range replicas {
+					if replicas[i] == r {
+						got = append(got, i)
+					}
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	random := Random()
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, replicas, random.pick(replicas))
+	}
+}
@@ -22,3 +22,20 @@ func (r Result) RowsAffected() (int64, error) {
 	}
 	return r.res.RowsAffected()
 }
+
+var _ sql.Result = sqlResult{}
+
+// sqlResult 是 sql.Result 的简单实现
+// 用于 RETURNING 这种不经过 ExecContext，需要自己汇总结果的场景
+type sqlResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (s sqlResult) LastInsertId() (int64, error) {
+	return s.lastInsertId, nil
+}
+
+func (s sqlResult) RowsAffected() (int64, error) {
+	return s.rowsAffected, nil
+}
@@ -0,0 +1,86 @@
+package morm
+
+import (
+	"context"
+	"github.com/NotFound1911/morm/errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy 事务的重试策略
+// 只有方言认为可以重试的错误才会重新执行整个事务，例如死锁、等待锁超时
+type RetryPolicy struct {
+	// MaxAttempts 最多执行的次数，包含第一次，小于等于 1 代表不重试
+	MaxAttempts int
+	// Backoff 第一次重试之前等待的时间，之后每次翻倍
+	Backoff time.Duration
+	// MaxBackoff 等待时间的上限，0 代表没有上限
+	MaxBackoff time.Duration
+	// Jitter 随机抖动的比例，取值 [0, 1]
+	// 实际等待的时间在 backoff * (1 - Jitter) 和 backoff 之间，避免多个事务同时重试再次冲突
+	Jitter float64
+	// AttemptTimeout 每次执行的超时时间，0 代表不限制
+	AttemptTimeout time.Duration
+}
+
+// DBWithTxRetry DoTx 遇到可以重试的错误的时候重新执行整个事务
+func DBWithTxRetry(p RetryPolicy) DBOption {
+	return func(db *DB) error {
+		db.retry = &p
+		return nil
+	}
+}
+
+// backoff 第 attempt 次执行失败之后需要等待的时间，attempt 从 1 开始
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// do 执行 fn 直到成功、遇到不能重试的错误或者达到最大次数
+// fn 返回的 retryable 代表这一次的错误是否可以重试
+// 重试过的话，返回的错误会记录执行的次数
+func (p *RetryPolicy) do(ctx context.Context, fn func(ctx context.Context) (retryable bool, err error)) error {
+	var err error
+	attempt := 0
+	for {
+		attempt++
+		var retryable bool
+		retryable, err = p.attempt(ctx, fn)
+		if err == nil || !retryable || attempt >= p.MaxAttempts {
+			break
+		}
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errs.NewErrTxRetryFailed(attempt, err)
+		case <-timer.C:
+		}
+	}
+	if err != nil && attempt > 1 {
+		return errs.NewErrTxRetryFailed(attempt, err)
+	}
+	return err
+}
+
+func (p *RetryPolicy) attempt(ctx context.Context, fn func(ctx context.Context) (bool, error)) (bool, error) {
+	if p.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.AttemptTimeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
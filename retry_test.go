@@ -0,0 +1,209 @@
+package morm
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type sqlStateErr string
+
+func (e sqlStateErr) Error() string {
+	return "pq: " + string(e)
+}
+
+func (e sqlStateErr) SQLState() string {
+	return string(e)
+}
+
+func TestDialect_isRetryable(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		err     error
+		want    bool
+	}{
+		{
+			name:    "mysql deadlock",
+			dialect: MySQL,
+			err:     &mysql.MySQLError{Number: 1213, Message: "Deadlock found"},
+			want:    true,
+		},
+		{
+			name:    "mysql lock wait timeout",
+			dialect: MySQL,
+			err:     &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"},
+			want:    true,
+		},
+		{
+			name:    "mysql duplicate entry",
+			dialect: MySQL,
+			err:     &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+		},
+		{
+			name:    "sqlite busy",
+			dialect: SQLite3,
+			err:     errors.New("database is locked"),
+			want:    true,
+		},
+		{
+			name:    "sqlite constraint",
+			dialect: SQLite3,
+			err:     errors.New("UNIQUE constraint failed: test_model.id"),
+		},
+		{
+			name:    "serialization failure",
+			dialect: standardSQL{},
+			err:     sqlStateErr("40001"),
+			want:    true,
+		},
+		{
+			name:    "postgres deadlock",
+			dialect: standardSQL{},
+			err:     sqlStateErr("40P01"),
+			want:    true,
+		},
+		{
+			name:    "unique violation",
+			dialect: standardSQL{},
+			err:     sqlStateErr("23505"),
+		},
+		{
+			name:    "nil",
+			dialect: SQLite3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.dialect.isRetryable(tc.err))
+		})
+	}
+}
+
+func TestDB_DoTx_Retry(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	mockErr := errors.New("mock error")
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Jitter: 0.5}
+	testCases := []struct {
+		name    string
+		mock    func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "success after deadlock",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnError(deadlock)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "exhausted",
+			mock: func(mock sqlmock.Sqlmock) {
+				for i := 0; i < 3; i++ {
+					mock.ExpectBegin()
+					mock.ExpectExec("INSERT .*").WillReturnError(deadlock)
+					mock.ExpectRollback()
+				}
+			},
+			wantErr: errs.NewErrTxRetryFailed(3, deadlock),
+		},
+		{
+			name: "not retryable",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnError(mockErr)
+				mock.ExpectRollback()
+			},
+			wantErr: mockErr,
+		},
+		{
+			name: "not retryable after retry",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnError(deadlock)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnError(mockErr)
+				mock.ExpectRollback()
+			},
+			wantErr: errs.NewErrTxRetryFailed(2, mockErr),
+		},
+		{
+			name: "commit deadlock",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit().WillReturnError(deadlock)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB(mockDB, DBWithTxRetry(policy))
+			require.NoError(t, err)
+			tc.mock(mock)
+
+			err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+				return NewInserter[TestModel](tx).Values(&TestModel{}).Exec(ctx).Err()
+			}, nil)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	// 返回的错误仍然可以找到原本的错误
+	err := errs.NewErrTxRetryFailed(3, deadlock)
+	var me *mysql.MySQLError
+	assert.True(t, errors.As(err, &me))
+	assert.Equal(t, uint16(1213), me.Number)
+}
+
+func TestDB_DoTx_AttemptTimeout(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithTxRetry(RetryPolicy{MaxAttempts: 2, AttemptTimeout: time.Second}))
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.True(t, time.Until(deadline) <= time.Second)
+		return nil
+	}, nil)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := &RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, p.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(4))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(100))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(2)
+		assert.True(t, d > 10*time.Millisecond && d <= 20*time.Millisecond)
+	}
+}
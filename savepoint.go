@@ -0,0 +1,101 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/NotFound1911/morm/errors"
+)
+
+type savepointOpt string
+
+const (
+	savepointCreate   savepointOpt = "SAVEPOINT"
+	savepointRollback savepointOpt = "ROLLBACK TO SAVEPOINT"
+	savepointRelease  savepointOpt = "RELEASE SAVEPOINT"
+)
+
+// Savepoint 在事务中创建一个保存点
+// 保存点的名字不能使用占位符，所以只允许字母、数字和下划线，并且不能以数字开头
+func (t *Tx) Savepoint(ctx context.Context, name string) error {
+	return t.savepoint(ctx, savepointCreate, name)
+}
+
+// RollbackTo 回滚到保存点，保存点之后的修改会被撤销，但是保存点本身仍然保留
+func (t *Tx) RollbackTo(ctx context.Context, name string) error {
+	return t.savepoint(ctx, savepointRollback, name)
+}
+
+// Release 释放保存点，保存点之后的修改会保留在事务中
+func (t *Tx) Release(ctx context.Context, name string) error {
+	return t.savepoint(ctx, savepointRelease, name)
+}
+
+func (t *Tx) savepoint(ctx context.Context, op savepointOpt, name string) error {
+	if !validSavepoint(name) {
+		return errs.NewErrInvalidSavepoint(name)
+	}
+	c := t.getCore()
+	b := &builder{
+		core:    c,
+		dialect: c.dialect,
+		quoter:  c.dialect.quoter(),
+	}
+	if err := c.dialect.buildSavepoint(b, op, name); err != nil {
+		return err
+	}
+	// 保存点的名字每次都不一样，而且 MySQL 的保存点语句不一定支持预编译，所以不经过语句缓存
+	_, err := t.tx.ExecContext(ctx, b.sqlBuilder.String())
+	return err
+}
+
+func validSavepoint(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// DoTx 在事务里面再开一个"事务"，实际上是创建一个保存点
+// fn 返回 error 或者 panic 的时候回滚到保存点，外层的事务可以继续执行，否则释放保存点
+// 这样内部调用了 DoTx 的代码既可以独立使用，也可以加入外层的事务
+// 回滚到保存点的时候，fn 里面注册的 AfterCommit 会被丢弃，AfterRollback 会立刻执行
+// opts 会被忽略，嵌套的事务只能沿用外层事务的隔离级别
+func (t *Tx) DoTx(ctx context.Context,
+	fn func(ctx context.Context, tx *Tx) error,
+	opts *sql.TxOptions) (err error) {
+	t.savepoints++
+	name := fmt.Sprintf("morm_sp_%d", t.savepoints)
+	if err = t.Savepoint(ctx, name); err != nil {
+		return err
+	}
+	mark := t.markCallbacks()
+	defer func() {
+		if e := recover(); e != nil || err != nil {
+			if e != nil {
+				err = errs.NewErrTxFuncFailed(e)
+			}
+			rE := t.RollbackTo(ctx, name)
+			if rE != nil {
+				err = errs.NewErrTxRollbackFailed(rE)
+				return
+			}
+			t.rollbackCallbacks(mark)
+		} else {
+			err = t.Release(ctx, name)
+			if err != nil {
+				err = errs.NewErrTxCommitFailed(err)
+			}
+		}
+	}()
+	err = fn(ctx, t)
+	return err
+}
@@ -0,0 +1,84 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTx_Savepoint(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT `sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, tx.Savepoint(ctx, "sp_1"))
+	assert.NoError(t, tx.RollbackTo(ctx, "sp_1"))
+	assert.NoError(t, tx.Release(ctx, "sp_1"))
+	assert.Equal(t, errs.NewErrInvalidSavepoint("sp`; DROP TABLE a"), tx.Savepoint(ctx, "sp`; DROP TABLE a"))
+	assert.Equal(t, errs.NewErrInvalidSavepoint("1sp"), tx.Savepoint(ctx, "1sp"))
+	assert.Equal(t, errs.NewErrInvalidSavepoint(""), tx.Savepoint(ctx, ""))
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_DoTx(t *testing.T) {
+	db := memoryDBWithDB("tx_do_tx", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(shardingOrderCreateSQL("sharding_order"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	mockErr := errors.New("mock error")
+
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		// 内层成功，保留修改
+		err := tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+			return NewInserter[ShardingOrder](tx).Values(&ShardingOrder{Id: 1, UserId: 1, Amount: 10}).Exec(ctx).Err()
+		}, nil)
+		require.NoError(t, err)
+
+		// 内层返回错误，只回滚内层的修改
+		err = tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+			res := NewInserter[ShardingOrder](tx).Values(&ShardingOrder{Id: 2, UserId: 2, Amount: 20}).Exec(ctx)
+			require.NoError(t, res.Err())
+			return mockErr
+		}, nil)
+		assert.Equal(t, mockErr, err)
+
+		// 内层 panic，同样只回滚内层的修改
+		err = tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+			res := NewInserter[ShardingOrder](tx).Values(&ShardingOrder{Id: 3, UserId: 3, Amount: 30}).Exec(ctx)
+			require.NoError(t, res.Err())
+			// 多层嵌套
+			return tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+				panic("mock panic")
+			}, nil)
+		}, nil)
+		assert.Equal(t, errs.NewErrTxFuncFailed("mock panic"), err)
+
+		return NewInserter[ShardingOrder](tx).Values(&ShardingOrder{Id: 4, UserId: 4, Amount: 40}).Exec(ctx).Err()
+	}, nil)
+	require.NoError(t, err)
+
+	res, err := NewSelector[ShardingOrder](db).OrderBy(Asc("Id")).GetMulti(ctx)
+	require.NoError(t, err)
+	ids := make([]int64, 0, len(res))
+	for _, o := range res {
+		ids = append(ids, o.Id)
+	}
+	assert.Equal(t, []int64{1, 4}, ids)
+}
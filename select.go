@@ -11,9 +11,15 @@ type Selector[T any] struct {
 	orderBys []OrderBy
 	limit    int
 	offset   int
-	groupBys []Column
+	groupBys []Expression
 	having   []Predicate
 	columns  []Selectable
+	distinct bool
+	ctes     []CTE
+	rollup   bool
+	windows  []namedWindow
+	lock     *lock
+	primary  bool
 
 	sess session
 }
@@ -23,13 +29,42 @@ func (s *Selector[T]) Select(cols ...Selectable) *Selector[T] {
 	return s
 }
 
+// With 在查询前面加上 WITH 子句
+func (s *Selector[T]) With(ctes ...CTE) *Selector[T] {
+	s.ctes = ctes
+	return s
+}
+
+// Distinct 设置 SELECT DISTINCT
+func (s *Selector[T]) Distinct() *Selector[T] {
+	s.distinct = true
+	return s
+}
+
 func (s *Selector[T]) From(table TableReference) *Selector[T] {
 	s.table = table
 	return s
 }
 
+// Clone 复制一个 Selector，可以把一个基础的查询作为模板，针对每个请求分别修改
+// 修改复制出来的 Selector 不会影响原本的 Selector
+func (s *Selector[T]) Clone() *Selector[T] {
+	res := *s
+	res.builder.reset()
+	res.where = append([]Predicate(nil), s.where...)
+	res.orderBys = append([]OrderBy(nil), s.orderBys...)
+	res.groupBys = append([]Expression(nil), s.groupBys...)
+	res.having = append([]Predicate(nil), s.having...)
+	res.columns = append([]Selectable(nil), s.columns...)
+	res.ctes = append([]CTE(nil), s.ctes...)
+	res.windows = append([]namedWindow(nil), s.windows...)
+	return &res
+}
+
 // Build 构建query
+// 子句的顺序是 WITH, SELECT, FROM, WHERE, GROUP BY, HAVING, WINDOW, ORDER BY, LIMIT/OFFSET, 锁定读
 func (s *Selector[T]) Build() (*Query, error) {
+	s.reset()
 	var (
 		t   T
 		err error
@@ -38,7 +73,13 @@ func (s *Selector[T]) Build() (*Query, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = s.buildWith(s.ctes); err != nil {
+		return nil, err
+	}
 	s.sqlBuilder.WriteString("SELECT ")
+	if s.distinct {
+		s.sqlBuilder.WriteString("DISTINCT ")
+	}
 	if err = s.buildColumns(); err != nil {
 		return nil, err
 	}
@@ -49,36 +90,10 @@ func (s *Selector[T]) Build() (*Query, error) {
 	// 构造where
 	if len(s.where) > 0 {
 		s.sqlBuilder.WriteString(" WHERE ")
-		if err := s.buildPredicates(s.where); err != nil {
+		if err = s.buildPredicates(s.where); err != nil {
 			return nil, err
 		}
 	}
-	// 构造order by
-	if len(s.orderBys) > 0 {
-		s.sqlBuilder.WriteString(" ORDER BY ")
-		for i, order := range s.orderBys {
-			if i > 0 {
-				s.sqlBuilder.WriteByte(',')
-			}
-			fd, ok := s.model.FieldMap[order.col]
-			if !ok {
-				return nil, errs.NewErrUnknownField(order.col)
-			}
-			s.sqlBuilder.WriteByte('`')
-			s.sqlBuilder.WriteString(fd.ColName)
-			s.sqlBuilder.WriteByte('`')
-			s.sqlBuilder.WriteByte(' ')
-			s.sqlBuilder.WriteString(order.fun)
-		}
-	}
-	if s.limit > 0 {
-		s.sqlBuilder.WriteString(" LIMIT ?")
-		s.args = append(s.args, s.limit)
-	}
-	if s.offset > 0 {
-		s.sqlBuilder.WriteString(" OFFSET ?")
-		s.args = append(s.args, s.offset)
-	}
 	// group by
 	if err = s.buildGroupBy(); err != nil {
 		return nil, err
@@ -87,6 +102,25 @@ func (s *Selector[T]) Build() (*Query, error) {
 	if err = s.buildHaving(); err != nil {
 		return nil, err
 	}
+	// window
+	if err = s.buildWindows(); err != nil {
+		return nil, err
+	}
+	// 构造order by
+	if len(s.orderBys) > 0 {
+		s.sqlBuilder.WriteString(" ORDER BY ")
+		if err = s.buildOrderBys(s.orderBys); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.dialect.buildLimitOffset(&s.builder, s.limit, s.offset); err != nil {
+		return nil, err
+	}
+	if s.lock != nil {
+		if err = s.dialect.buildLock(&s.builder, s.lock); err != nil {
+			return nil, err
+		}
+	}
 	s.sqlBuilder.WriteString(";")
 	return &Query{
 		SQL:  s.sqlBuilder.String(),
@@ -97,13 +131,13 @@ func (s *Selector[T]) Build() (*Query, error) {
 func (s *Selector[T]) buildTable(table TableReference) error {
 	switch tab := table.(type) {
 	case nil:
-		s.quote(s.model.TableName)
+		s.quote(s.tableName(s.model))
 	case Table:
 		model, err := s.r.Get(tab.entity)
 		if err != nil {
 			return err
 		}
-		s.quote(model.TableName)
+		s.quote(s.tableName(model))
 		if tab.alias != "" {
 			s.sqlBuilder.WriteString(" AS ")
 			s.quote(tab.alias)
@@ -112,6 +146,8 @@ func (s *Selector[T]) buildTable(table TableReference) error {
 		return s.buildJoin(tab)
 	case Subquery:
 		return s.buildSubquery(tab, true)
+	case CTE:
+		s.quote(tab.name)
 	default:
 		return errs.NewErrUnsupportedExpressionType(tab)
 	}
@@ -164,6 +200,12 @@ func (s *Selector[T]) Where(ps ...Predicate) *Selector[T] {
 	return s
 }
 
+// AndWhere 在已有的查询条件上追加条件，和已有的条件是 AND 的关系
+func (s *Selector[T]) AndWhere(ps ...Predicate) *Selector[T] {
+	s.where = append(s.where[:len(s.where):len(s.where)], ps...)
+	return s
+}
+
 func NewSelector[T any](sess session) *Selector[T] {
 	c := sess.getCore()
 	return &Selector[T]{
@@ -176,7 +218,43 @@ func NewSelector[T any](sess session) *Selector[T] {
 	}
 }
 
+// UsePrimary 在读写分离的时候强制使用主库
+func (s *Selector[T]) UsePrimary() *Selector[T] {
+	s.primary = true
+	return s
+}
+
+// checkLock 锁定读离开了事务没有意义，锁会在语句结束之后立刻释放
+// 通过 DoTxCtx 放入 context 的事务也可以
+func (s *Selector[T]) checkLock(ctx context.Context) error {
+	if s.lock == nil {
+		return nil
+	}
+	switch sess := s.sess.(type) {
+	case *Tx:
+		return nil
+	case *DB:
+		if _, ok := sess.TxFromContext(ctx); ok {
+			return nil
+		}
+	}
+	return errs.NewErrLockOutsideTx(s.lock.mode)
+}
+
 func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
+	if err := s.checkLock(ctx); err != nil {
+		return nil, err
+	}
+	if s.primary {
+		ctx = UsePrimary(ctx)
+	}
+	if sd, ok := s.sess.(*ShardingDB); ok {
+		res, err := shardingGetMulti[T](ctx, sd, s.Clone().Limit(1))
+		if err != nil {
+			return nil, err
+		}
+		return res[0], nil
+	}
 	res := get[T](ctx, s.core, s.sess, &QueryContext{
 		Builder: s,
 		Type:    "SELECT",
@@ -188,7 +266,16 @@ func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
 }
 
 func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	res := getMultiHandler[T](ctx, s.core, s.sess, &QueryContext{
+	if err := s.checkLock(ctx); err != nil {
+		return nil, err
+	}
+	if s.primary {
+		ctx = UsePrimary(ctx)
+	}
+	if sd, ok := s.sess.(*ShardingDB); ok {
+		return shardingGetMulti[T](ctx, sd, s)
+	}
+	res := getMulti[T](ctx, s.core, s.sess, &QueryContext{
 		Builder: s,
 		Type:    "SELECT",
 	})
@@ -204,22 +291,35 @@ type Selectable interface {
 	target() TableReference
 }
 
-// GroupBy 设置 group by 子句
-func (s *Selector[T]) GroupBy(cols ...Column) *Selector[T] {
+// GroupBy 设置 group by 子句，可以是列，也可以是 CASE WHEN 或者函数调用这类表达式
+func (s *Selector[T]) GroupBy(cols ...Expression) *Selector[T] {
 	s.groupBys = cols
 	return s
 }
+
+// WithRollup 在 GROUP BY 后面加上 WITH ROLLUP，只有 MySQL 支持
+func (s *Selector[T]) WithRollup() *Selector[T] {
+	s.rollup = true
+	return s
+}
+
 func (s *Selector[T]) buildGroupBy() error {
+	if s.rollup && len(s.groupBys) == 0 {
+		return errs.NewErrClauseRequires("WITH ROLLUP", "GROUP BY")
+	}
 	if len(s.groupBys) > 0 {
 		s.sqlBuilder.WriteString(" GROUP BY ")
 		for i, group := range s.groupBys {
 			if i > 0 {
 				s.sqlBuilder.WriteByte(',')
 			}
-			if err := s.buildColumn(group, false); err != nil {
+			if err := s.buildExpression(group); err != nil {
 				return err
 			}
 		}
+		if s.rollup {
+			return s.dialect.buildRollup(&s.builder)
+		}
 	}
 	return nil
 }
@@ -251,6 +351,12 @@ func (s *Selector[T]) OrderBy(orderBys ...OrderBy) *Selector[T] {
 	return s
 }
 
+// AppendOrderBy 在已有的排序后面追加排序
+func (s *Selector[T]) AppendOrderBy(orderBys ...OrderBy) *Selector[T] {
+	s.orderBys = append(s.orderBys[:len(s.orderBys):len(s.orderBys)], orderBys...)
+	return s
+}
+
 // 构建筛选列
 func (s *Selector[T]) buildColumns() error {
 	if len(s.columns) == 0 {
@@ -270,6 +376,26 @@ func (s *Selector[T]) buildColumns() error {
 			if err := s.buildAggregate(val, true); err != nil {
 				return err
 			}
+		case WindowFunc: // 窗口函数
+			if err := s.buildWindowFunc(val, true); err != nil {
+				return err
+			}
+		case CaseExpr: // CASE WHEN
+			if err := s.buildCase(val, true); err != nil {
+				return err
+			}
+		case FuncExpr: // 函数调用
+			if err := s.buildFunc(val, true); err != nil {
+				return err
+			}
+		case MatchExpr: // 全文检索的相关度
+			if err := s.buildMatch(val, true); err != nil {
+				return err
+			}
+		case JSONExpr: // JSON 取值
+			if err := s.buildJSON(val, true); err != nil {
+				return err
+			}
 		case RawExpr: //  表达式
 			s.sqlBuilder.WriteString(val.raw)
 			if len(val.args) != 0 {
@@ -281,21 +407,50 @@ func (s *Selector[T]) buildColumns() error {
 }
 
 type OrderBy struct {
-	col string
-	fun string
+	expr Expression
+	fun  string
+	// nulls 空值的位置，为空代表使用数据库的默认行为
+	nulls string
+}
+
+const (
+	nullsFirst = "FIRST"
+	nullsLast  = "LAST"
+)
+
+// NullsFirst 空值排在前面，MySQL 不支持
+func (o OrderBy) NullsFirst() OrderBy {
+	o.nulls = nullsFirst
+	return o
+}
+
+// NullsLast 空值排在后面，MySQL 不支持
+func (o OrderBy) NullsLast() OrderBy {
+	o.nulls = nullsLast
+	return o
 }
 
 func Asc(col string) OrderBy { // 顺序
+	return AscExpr(C(col))
+}
+
+func Desc(col string) OrderBy { // 逆序
+	return DescExpr(C(col))
+}
+
+// AscExpr 按照表达式顺序排列，例如其它表的列、聚合函数、别名、CASE WHEN 或者函数调用
+func AscExpr(e Expression) OrderBy {
 	return OrderBy{
-		col: col,
-		fun: "ASC",
+		expr: e,
+		fun:  "ASC",
 	}
 }
 
-func Desc(col string) OrderBy { // 逆序
+// DescExpr 按照表达式逆序排列
+func DescExpr(e Expression) OrderBy {
 	return OrderBy{
-		col: col,
-		fun: "DESC",
+		expr: e,
+		fun:  "DESC",
 	}
 }
 
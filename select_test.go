@@ -7,6 +7,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/NotFound1911/morm/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -199,7 +200,7 @@ func TestSelector_Subquery(t *testing.T) {
 				return NewSelector[Order](db).Where(C("Id").GT(Some(sub)), C("Id").LT(Any(sub)))
 			}(),
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `order` WHERE (`id` > SOME (SELECT `order_id` FROM `order_detail`)) AND (`id` < ANY (SELECT `order_id` FROM `order_detail`;SELECT `order_id` FROM `order_detail`));",
+				SQL: "SELECT * FROM `order` WHERE (`id` > SOME (SELECT `order_id` FROM `order_detail`)) AND (`id` < ANY (SELECT `order_id` FROM `order_detail`));",
 			},
 		},
 	}
@@ -670,6 +671,51 @@ func TestSelector_OrderBy(t *testing.T) {
 			q:       NewSelector[TestModel](db).OrderBy(Asc("Invalid")),
 			wantErr: errs.NewErrUnknownField("Invalid"),
 		},
+		{
+			name: "joined table column",
+			q: func() QueryBuilder {
+				t1 := TableOf(&TestModel{}).As("t1")
+				t2 := TableOf(&Category{}).As("t2")
+				return NewSelector[TestModel](db).From(t1.Join(t2).On(t1.C("Id").EQ(t2.C("Id")))).
+					OrderBy(DescExpr(t2.C("ParentId")), AscExpr(t1.C("Age")))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (`test_model` AS `t1` JOIN `category` AS `t2` ON `t1`.`id` = `t2`.`id`) " +
+					"ORDER BY `t2`.`parent_id` DESC,`t1`.`age` ASC;",
+			},
+		},
+		{
+			name:    "invalid joined table column",
+			q:       NewSelector[TestModel](db).OrderBy(AscExpr(TableOf(&Category{}).C("Age"))),
+			wantErr: errs.NewErrUnknownField("Age"),
+		},
+		{
+			name: "aggregate",
+			q:    NewSelector[TestModel](db).Select(C("Age")).OrderBy(DescExpr(Count("Id"))),
+			wantQuery: &Query{
+				SQL: "SELECT `age` FROM `test_model` ORDER BY COUNT(`id`) DESC;",
+			},
+		},
+		{
+			name: "alias",
+			q:    NewSelector[TestModel](db).Select(Avg("Age").As("avg_age")).OrderBy(DescExpr(Alias("avg_age"))),
+			wantQuery: &Query{
+				SQL: "SELECT AVG(`age`) AS `avg_age` FROM `test_model` ORDER BY `avg_age` DESC;",
+			},
+		},
+		{
+			name: "raw",
+			q:    NewSelector[TestModel](db).OrderBy(AscExpr(Raw("FIELD(`id`,?,?)", 3, 1))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` ORDER BY FIELD(`id`,?,?) ASC;",
+				Args: []any{3, 1},
+			},
+		},
+		{
+			name:    "nulls first",
+			q:       NewSelector[TestModel](db).OrderBy(Asc("LastName").NullsFirst()),
+			wantErr: errs.NewErrUnsupportedByDialect("MySQL", "NULLS FIRST"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -693,12 +739,9 @@ func TestSelector_OffsetLimit(t *testing.T) {
 		wantErr   error
 	}{
 		{
-			name: "offset only",
-			q:    NewSelector[TestModel](db).Offset(10),
-			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` OFFSET ?;",
-				Args: []any{10},
-			},
+			name:    "offset only",
+			q:       NewSelector[TestModel](db).Offset(10),
+			wantErr: errs.NewErrOffsetWithoutLimit("MySQL"),
 		},
 		{
 			name: "limit only",
@@ -827,6 +870,33 @@ func TestSelector_GroupBy(t *testing.T) {
 			q:       NewSelector[TestModel](db).GroupBy(C("Invalid")),
 			wantErr: errs.NewErrUnknownField("Invalid"),
 		},
+		{
+			name: "joined table column",
+			q: func() QueryBuilder {
+				t1 := TableOf(&TestModel{}).As("t1")
+				t2 := TableOf(&Category{}).As("t2")
+				return NewSelector[TestModel](db).Select(t2.C("ParentId"), Count("Id").As("cnt")).
+					From(t1.Join(t2).On(t1.C("Id").EQ(t2.C("Id")))).
+					GroupBy(t2.C("ParentId")).Having(Alias("cnt").GT(1))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `t2`.`parent_id`,COUNT(`id`) AS `cnt` FROM (`test_model` AS `t1` JOIN `category` AS `t2` ON `t1`.`id` = `t2`.`id`) " +
+					"GROUP BY `t2`.`parent_id` HAVING `cnt` > ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "with rollup",
+			q:    NewSelector[TestModel](db).Select(C("Age"), Count("Id")).GroupBy(C("Age")).WithRollup(),
+			wantQuery: &Query{
+				SQL: "SELECT `age`,COUNT(`id`) FROM `test_model` GROUP BY `age` WITH ROLLUP;",
+			},
+		},
+		{
+			name:    "rollup without group by",
+			q:       NewSelector[TestModel](db).WithRollup(),
+			wantErr: errs.NewErrClauseRequires("WITH ROLLUP", "GROUP BY"),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -839,3 +909,307 @@ func TestSelector_GroupBy(t *testing.T) {
 		})
 	}
 }
+
+func TestSelector_Distinct(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "distinct",
+			q:    NewSelector[TestModel](db).Select(C("FirstName")).Distinct(),
+			wantQuery: &Query{
+				SQL: "SELECT DISTINCT `first_name` FROM `test_model`;",
+			},
+		},
+		{
+			name: "count distinct",
+			q:    NewSelector[TestModel](db).Select(CountDistinct("FirstName").As("cnt")),
+			wantQuery: &Query{
+				SQL: "SELECT COUNT(DISTINCT `first_name`) AS `cnt` FROM `test_model`;",
+			},
+		},
+		{
+			name: "count distinct in having",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(CountDistinct("FirstName").GT(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING COUNT(DISTINCT `first_name`) > ?;",
+				Args: []any{1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_SQLite3_OrderByGroupBy(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "nulls first",
+			q:    NewSelector[TestModel](db).OrderBy(Asc("LastName").NullsFirst(), Desc("Id")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `last_name` ASC NULLS FIRST,`id` DESC;",
+			},
+		},
+		{
+			name: "nulls last",
+			q:    NewSelector[TestModel](db).OrderBy(Desc("LastName").NullsLast()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `last_name` DESC NULLS LAST;",
+			},
+		},
+		{
+			name:    "with rollup",
+			q:       NewSelector[TestModel](db).GroupBy(C("Age")).WithRollup(),
+			wantErr: errs.NewErrUnsupportedByDialect("SQLite", "WITH ROLLUP"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_BuildTwice(t *testing.T) {
+	db := memoryDB(t)
+	sub := NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)).AsSubquery("sub")
+	s := NewSelector[TestModel](db).Where(C("Id").InQuery(sub)).Limit(10).Offset(20)
+	q1, err := s.Build()
+	require.NoError(t, err)
+	q2, err := s.Build()
+	require.NoError(t, err)
+	want := &Query{
+		SQL:  "SELECT * FROM `test_model` WHERE `id` IN (SELECT `id` FROM `test_model` WHERE `age` > ?) LIMIT ? OFFSET ?;",
+		Args: []any{18, 10, 20},
+	}
+	assert.Equal(t, want, q1)
+	assert.Equal(t, want, q2)
+}
+
+func TestSelector_Clone(t *testing.T) {
+	db := memoryDB(t)
+	base := NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).
+		Where(C("Age").GT(18)).OrderBy(Desc("Id"))
+	_, err := base.Build()
+	require.NoError(t, err)
+
+	page := base.Clone().Limit(10).Offset(10)
+	other := base.Clone().Where(C("Age").LT(60)).OrderBy(Asc("Age"))
+
+	q, err := page.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ? ORDER BY `id` DESC LIMIT ? OFFSET ?;",
+		Args: []any{18, 10, 10},
+	}, q)
+	q, err = other.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT `id`,`first_name` FROM `test_model` WHERE `age` < ? ORDER BY `age` ASC;",
+		Args: []any{60},
+	}, q)
+	q, err = base.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ? ORDER BY `id` DESC;",
+		Args: []any{18},
+	}, q)
+}
+
+// countBuilder 记录 Build 被调用的次数
+type countBuilder struct {
+	QueryBuilder
+	cnt int
+}
+
+func (c *countBuilder) Build() (*Query, error) {
+	c.cnt++
+	return c.QueryBuilder.Build()
+}
+
+func TestQueryContext_Query(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	var logged *Query
+	db, err := OpenDB(mockDB, DBWithMiddleware(func(next HanderFunc) HanderFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			q, err := qc.Query()
+			if err != nil {
+				return &QueryResult{Err: err}
+			}
+			logged = q
+			return next(ctx, qc)
+		}
+	}))
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT \\* FROM `test_model` LIMIT \\?;").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	cb := &countBuilder{QueryBuilder: NewSelector[TestModel](db).Limit(1)}
+	res := get[TestModel](context.Background(), db.core, db, &QueryContext{
+		Builder: cb,
+		Type:    "SELECT",
+	})
+	require.NoError(t, res.Err)
+	assert.Equal(t, 1, cb.cnt)
+	assert.Equal(t, &Query{SQL: "SELECT * FROM `test_model` LIMIT ?;", Args: []any{1}}, logged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSelector_ClauseOrder 每种方言下完整的子句顺序
+func TestSelector_ClauseOrder(t *testing.T) {
+	full := func(db *DB) *Selector[TestModel] {
+		adult := With("adult", NewSelector[TestModel](db).Where(C("Age").GT(18)))
+		return NewSelector[TestModel](db).With(adult).
+			Select(C("Age"), CountAll().As("cnt"), RowNumber().OverWindow("w").As("rn")).
+			From(adult).
+			Where(C("FirstName").EQ("Tom")).
+			GroupBy(C("Age")).
+			Having(CountAll().GT(1)).
+			Window("w", Window{OrderBy: []OrderBy{Asc("Age")}}).
+			OrderBy(Desc("Age")).
+			Limit(10).Offset(20).
+			ForUpdate()
+	}
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		q         func(db *DB) QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return full(db)
+			},
+			wantQuery: &Query{
+				SQL: "WITH `adult` AS (SELECT * FROM `test_model` WHERE `age` > ?) " +
+					"SELECT `age`,COUNT(*) AS `cnt`,ROW_NUMBER() OVER `w` AS `rn` FROM `adult` " +
+					"WHERE `first_name` = ? GROUP BY `age` HAVING COUNT(*) > ? " +
+					"WINDOW `w` AS (ORDER BY `age` ASC) ORDER BY `age` DESC LIMIT ? OFFSET ? FOR UPDATE;",
+				Args: []any{18, "Tom", 1, 10, 20},
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return full(db)
+			},
+			wantQuery: &Query{
+				SQL: "WITH `adult` AS (SELECT * FROM `test_model` WHERE `age` > ?) " +
+					"SELECT `age`,COUNT(*) AS `cnt`,ROW_NUMBER() OVER `w` AS `rn` FROM `adult` " +
+					"WHERE `first_name` = ? GROUP BY `age` HAVING COUNT(*) > ? " +
+					"WINDOW `w` AS (ORDER BY `age` ASC) ORDER BY `age` DESC LIMIT ? OFFSET ?;",
+				Args: []any{18, "Tom", 1, 10, 20},
+			},
+		},
+		{
+			name:    "mysql rollup",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Age"), CountAll()).
+					GroupBy(C("Age")).WithRollup().Having(CountAll().GT(1)).Limit(10)
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT `age`,COUNT(*) FROM `test_model` GROUP BY `age` WITH ROLLUP HAVING COUNT(*) > ? LIMIT ?;",
+				Args: []any{1, 10},
+			},
+		},
+		{
+			name:    "mysql offset without limit",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).GroupBy(C("Age")).Offset(10)
+			},
+			wantErr: errs.NewErrOffsetWithoutLimit("MySQL"),
+		},
+		{
+			name:    "sqlite offset without limit",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Offset(10)
+			},
+			wantErr: errs.NewErrOffsetWithoutLimit("SQLite"),
+		},
+		{
+			name:    "set operation offset without limit",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Union(NewSelector[TestModel](db)).Offset(10)
+			},
+			wantErr: errs.NewErrOffsetWithoutLimit("SQLite"),
+		},
+		{
+			name:    "rollup without group by",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).WithRollup()
+			},
+			wantErr: errs.NewErrClauseRequires("WITH ROLLUP", "GROUP BY"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := memoryDB(t, DBWithDialect(tc.dialect))
+			query, err := tc.q(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_SQLite3_ClauseOrder(t *testing.T) {
+	db := memoryDBWithDB("clause_order", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	ln := &sql.NullString{String: "Cat", Valid: true}
+	res := NewInserter[TestModel](db).Values(
+		&TestModel{Id: 1, FirstName: "Tom", Age: 20, LastName: ln},
+		&TestModel{Id: 2, FirstName: "Tom", Age: 20, LastName: ln},
+		&TestModel{Id: 3, FirstName: "Tom", Age: 30, LastName: ln},
+		&TestModel{Id: 4, FirstName: "Tom", Age: 30, LastName: ln},
+		&TestModel{Id: 5, FirstName: "Tom", Age: 40, LastName: ln},
+		&TestModel{Id: 6, FirstName: "Jerry", Age: 50, LastName: ln},
+	).Exec(context.Background())
+	require.NoError(t, res.Err())
+
+	got, err := NewSelector[TestModel](db).Select(C("Age")).
+		Where(C("FirstName").EQ("Tom")).
+		GroupBy(C("Age")).Having(CountAll().GT(1)).
+		OrderBy(Desc("Age")).Limit(1).Offset(1).
+		GetMulti(context.Background())
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, int8(20), got[0].Age)
+}
@@ -0,0 +1,218 @@
+package morm
+
+import (
+	"context"
+	"github.com/NotFound1911/morm/errors"
+)
+
+// 集合操作符
+type setOpt string
+
+const (
+	setOptUnion     setOpt = "UNION"
+	setOptUnionAll  setOpt = "UNION ALL"
+	setOptIntersect setOpt = "INTERSECT"
+	setOptExcept    setOpt = "EXCEPT"
+)
+
+func (o setOpt) String() string {
+	return string(o)
+}
+
+// SetOperation 用集合操作符组合多个查询
+// 例如 (SELECT ...) UNION (SELECT ...) ORDER BY ... LIMIT ?
+// ORDER BY 和 LIMIT 作用于整个结果集，列按照 T 的模型解析
+type SetOperation[T any] struct {
+	builder
+	first    *Selector[T]
+	operands []setOperand
+	orderBys []OrderBy
+	limit    int
+	offset   int
+
+	sess session
+}
+
+type setOperand struct {
+	op setOpt
+	q  QueryBuilder
+}
+
+func newSetOperation[T any](s *Selector[T], op setOpt, other QueryBuilder) *SetOperation[T] {
+	return &SetOperation[T]{
+		builder: builder{
+			core:    s.core,
+			dialect: s.dialect,
+			quoter:  s.quoter,
+		},
+		first:    s,
+		operands: []setOperand{{op: op, q: other}},
+		sess:     s.sess,
+	}
+}
+
+// Union 对应 UNION，结果会去重
+func (s *Selector[T]) Union(other QueryBuilder) *SetOperation[T] {
+	return newSetOperation[T](s, setOptUnion, other)
+}
+
+// UnionAll 对应 UNION ALL
+func (s *Selector[T]) UnionAll(other QueryBuilder) *SetOperation[T] {
+	return newSetOperation[T](s, setOptUnionAll, other)
+}
+
+// Intersect 对应 INTERSECT，MySQL 不支持
+func (s *Selector[T]) Intersect(other QueryBuilder) *SetOperation[T] {
+	return newSetOperation[T](s, setOptIntersect, other)
+}
+
+// Except 对应 EXCEPT，MySQL 不支持
+func (s *Selector[T]) Except(other QueryBuilder) *SetOperation[T] {
+	return newSetOperation[T](s, setOptExcept, other)
+}
+
+func (s *SetOperation[T]) Union(other QueryBuilder) *SetOperation[T] {
+	s.operands = append(s.operands, setOperand{op: setOptUnion, q: other})
+	return s
+}
+
+func (s *SetOperation[T]) UnionAll(other QueryBuilder) *SetOperation[T] {
+	s.operands = append(s.operands, setOperand{op: setOptUnionAll, q: other})
+	return s
+}
+
+func (s *SetOperation[T]) Intersect(other QueryBuilder) *SetOperation[T] {
+	s.operands = append(s.operands, setOperand{op: setOptIntersect, q: other})
+	return s
+}
+
+func (s *SetOperation[T]) Except(other QueryBuilder) *SetOperation[T] {
+	s.operands = append(s.operands, setOperand{op: setOptExcept, q: other})
+	return s
+}
+
+func (s *SetOperation[T]) OrderBy(orderBys ...OrderBy) *SetOperation[T] {
+	s.orderBys = orderBys
+	return s
+}
+
+func (s *SetOperation[T]) Limit(limit int) *SetOperation[T] {
+	s.limit = limit
+	return s
+}
+
+func (s *SetOperation[T]) Offset(offset int) *SetOperation[T] {
+	s.offset = offset
+	return s
+}
+
+func (s *SetOperation[T]) Build() (*Query, error) {
+	s.reset()
+	var (
+		t   T
+		err error
+	)
+	s.model, err = s.r.Get(&t)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.buildOperand(s.first); err != nil {
+		return nil, err
+	}
+	for _, operand := range s.operands {
+		s.sqlBuilder.WriteByte(' ')
+		if err = s.dialect.buildSetOperation(&s.builder, operand.op); err != nil {
+			return nil, err
+		}
+		s.sqlBuilder.WriteByte(' ')
+		if err = s.buildOperand(operand.q); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.orderBys) > 0 {
+		s.sqlBuilder.WriteString(" ORDER BY ")
+		if err = s.buildOrderBys(s.orderBys); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.dialect.buildLimitOffset(&s.builder, s.limit, s.offset); err != nil {
+		return nil, err
+	}
+	s.sqlBuilder.WriteByte(';')
+	return &Query{
+		SQL:  s.sqlBuilder.String(),
+		Args: s.args,
+	}, nil
+}
+
+func (s *SetOperation[T]) buildOperand(qb QueryBuilder) error {
+	q, err := qb.Build()
+	if err != nil {
+		return err
+	}
+	wrap := s.dialect.wrapSetOperand()
+	if o, ok := qb.(limitedQuery); ok && !wrap && o.hasOrderByOrLimit() {
+		// 没有括号的时候，ORDER BY 和 LIMIT 会作用于整个结果集，或者直接是语法错误
+		return errs.NewErrUnsupportedByDialect(s.dialect.name(), "集合操作的查询中使用 ORDER BY 或者 LIMIT")
+	}
+	if wrap {
+		s.sqlBuilder.WriteByte('(')
+	}
+	s.sqlBuilder.WriteString(q.SQL[:len(q.SQL)-1]) // 去掉;
+	if wrap {
+		s.sqlBuilder.WriteByte(')')
+	}
+	if len(q.Args) > 0 {
+		s.addArgs(q.Args...)
+	}
+	return nil
+}
+
+// limitedQuery 查询自身是否带有 ORDER BY 或者 LIMIT
+type limitedQuery interface {
+	hasOrderByOrLimit() bool
+}
+
+func (s *Selector[T]) hasOrderByOrLimit() bool {
+	return len(s.orderBys) > 0 || s.limit > 0 || s.offset > 0
+}
+
+func (s *SetOperation[T]) hasOrderByOrLimit() bool {
+	return len(s.orderBys) > 0 || s.limit > 0 || s.offset > 0
+}
+
+func (s *SetOperation[T]) Get(ctx context.Context) (*T, error) {
+	res := get[T](ctx, s.core, s.sess, &QueryContext{
+		Builder: s,
+		Type:    "SELECT",
+	})
+	if res.Result != nil {
+		return res.Result.(*T), res.Err
+	}
+	return nil, res.Err
+}
+
+func (s *SetOperation[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	res := getMulti[T](ctx, s.core, s.sess, &QueryContext{
+		Builder: s,
+		Type:    "SELECT",
+	})
+	if res.Result != nil {
+		return res.Result.([]*T), res.Err
+	}
+	return nil, res.Err
+}
+
+// AsSubquery 将集合操作的结果作为子查询，列以第一个查询为准
+func (s *SetOperation[T]) AsSubquery(alias string) Subquery {
+	table := s.first.table
+	if table == nil {
+		table = TableOf(new(T))
+	}
+	return Subquery{
+		s:       s,
+		alias:   alias,
+		table:   table,
+		columns: s.first.columns,
+	}
+}
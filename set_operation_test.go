@@ -0,0 +1,164 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSetOperation_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "union",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				Union(NewSelector[TestModel](db).Where(C("Age").LT(10))),
+			wantQuery: &Query{
+				SQL:  "(SELECT * FROM `test_model` WHERE `age` > ?) UNION (SELECT * FROM `test_model` WHERE `age` < ?);",
+				Args: []any{18, 10},
+			},
+		},
+		{
+			name: "union all with order by and limit",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				UnionAll(NewSelector[TestModel](db).Where(C("Age").LT(10))).
+				UnionAll(NewSelector[TestModel](db).Where(C("Id").EQ(1))).
+				OrderBy(Desc("Age")).Limit(10).Offset(5),
+			wantQuery: &Query{
+				SQL: "(SELECT * FROM `test_model` WHERE `age` > ?) UNION ALL " +
+					"(SELECT * FROM `test_model` WHERE `age` < ?) UNION ALL " +
+					"(SELECT * FROM `test_model` WHERE `id` = ?) ORDER BY `age` DESC LIMIT ? OFFSET ?;",
+				Args: []any{18, 10, 1, 10, 5},
+			},
+		},
+		{
+			name: "invalid order by",
+			q: NewSelector[TestModel](db).
+				Union(NewSelector[TestModel](db)).OrderBy(Asc("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "invalid operand",
+			q: NewSelector[TestModel](db).
+				Union(NewSelector[TestModel](db).Where(C("Invalid").EQ(1))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "intersect",
+			q: NewSelector[TestModel](db).
+				Intersect(NewSelector[TestModel](db)),
+			wantErr: errs.NewErrUnsupportedByDialect("MySQL", "INTERSECT"),
+		},
+		{
+			name: "except",
+			q: NewSelector[TestModel](db).
+				Except(NewSelector[TestModel](db)),
+			wantErr: errs.NewErrUnsupportedByDialect("MySQL", "EXCEPT"),
+		},
+		{
+			name: "subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id"), C("Age")).Where(C("Age").GT(18)).
+					Union(NewSelector[TestModel](db).Select(C("Id"), C("Age")).Where(C("Age").LT(10))).
+					AsSubquery("sub")
+				return NewSelector[TestModel](db).Select(sub.C("Age")).From(sub).Where(sub.C("Id").GT(3))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `sub`.`age` FROM ((SELECT `id`,`age` FROM `test_model` WHERE `age` > ?) UNION " +
+					"(SELECT `id`,`age` FROM `test_model` WHERE `age` < ?)) AS `sub` WHERE `sub`.`id` > ?;",
+				Args: []any{18, 10, 3},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSetOperation_SQLite3_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(SQLite3))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// SQLite 不允许括号
+			name: "union",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				Union(NewSelector[TestModel](db).Where(C("Age").LT(10))).Limit(3),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? UNION SELECT * FROM `test_model` WHERE `age` < ? LIMIT ?;",
+				Args: []any{18, 10, 3},
+			},
+		},
+		{
+			name: "intersect and except",
+			q: NewSelector[TestModel](db).
+				Intersect(NewSelector[TestModel](db).Where(C("Age").GT(18))).
+				Except(NewSelector[TestModel](db).Where(C("Id").EQ(1))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` INTERSECT SELECT * FROM `test_model` WHERE `age` > ? EXCEPT SELECT * FROM `test_model` WHERE `id` = ?;",
+				Args: []any{18, 1},
+			},
+		},
+		{
+			// 没有括号，子查询的 ORDER BY 和 LIMIT 不合法
+			name: "operand order by",
+			q: NewSelector[TestModel](db).
+				Union(NewSelector[TestModel](db).OrderBy(Asc("Age"))),
+			wantErr: errs.NewErrUnsupportedByDialect("SQLite", "集合操作的查询中使用 ORDER BY 或者 LIMIT"),
+		},
+		{
+			name: "first operand limit",
+			q: NewSelector[TestModel](db).Limit(1).
+				Union(NewSelector[TestModel](db)),
+			wantErr: errs.NewErrUnsupportedByDialect("SQLite", "集合操作的查询中使用 ORDER BY 或者 LIMIT"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSetOperation_GetMulti(t *testing.T) {
+	db := memoryDBWithDB("set_operation", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	res := NewInserter[TestModel](db).Values(
+		&TestModel{Id: 1, FirstName: "a", Age: 10, LastName: &sql.NullString{String: "A", Valid: true}},
+		&TestModel{Id: 2, FirstName: "b", Age: 20, LastName: &sql.NullString{String: "B", Valid: true}},
+		&TestModel{Id: 3, FirstName: "c", Age: 30, LastName: &sql.NullString{String: "C", Valid: true}},
+	).Exec(context.Background())
+	require.NoError(t, res.Err())
+
+	got, err := NewSelector[TestModel](db).Where(C("Age").GT(25)).
+		Union(NewSelector[TestModel](db).Where(C("Age").LT(15))).
+		OrderBy(Desc("Id")).GetMulti(context.Background())
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, []int64{3, 1}, []int64{got[0].Id, got[1].Id})
+}
@@ -0,0 +1,455 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/NotFound1911/morm/internal/valuer"
+	"github.com/NotFound1911/morm/model"
+	"reflect"
+	"sort"
+	"time"
+)
+
+var _ session = &ShardingDB{}
+
+// FanOutPolicy 查询条件中没有分片键的时候的处理策略
+type FanOutPolicy int
+
+const (
+	// FanOutMerge 发送到所有的分片，然后在内存中合并结果
+	FanOutMerge FanOutPolicy = iota
+	// FanOutReject 直接返回错误
+	FanOutReject
+)
+
+type ShardingOption func(db *ShardingDB) error
+
+// ShardingDB 分库分表
+// Selector、Inserter、Updater、Deleter 和 BulkUpdater 会根据分片键改写表名并且选择目标库，
+// 每个分库使用自己的 DB 执行，所以分库上的读写分离等配置依旧生效
+type ShardingDB struct {
+	core
+	dbs    map[string]*DB
+	rules  map[*model.Model]*shardingRule
+	fanOut FanOutPolicy
+	// maxConcurrency 广播查询的时候最多同时查询的分片数量
+	maxConcurrency int
+	// crossShardWrite 是否允许涉及多个分片的写操作
+	crossShardWrite bool
+}
+
+type shardingRule struct {
+	// key 分片键的字段名
+	key       string
+	algorithm ShardingAlgorithm
+}
+
+// NewShardingDB dbs 的 key 是库名，和分片算法返回的 Dst.DB 对应
+func NewShardingDB(dbs map[string]*DB, opts ...ShardingOption) (*ShardingDB, error) {
+	res := &ShardingDB{
+		core: core{
+			dialect:    MySQL,
+			r:          model.NewRegistry(),
+			valCreator: valuer.NewUnsafeValue,
+		},
+		dbs:   dbs,
+		rules: map[*model.Model]*shardingRule{},
+	}
+	for _, opt := range opts {
+		if err := opt(res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// ShardingWithDialect 生成 SQL 时使用的方言
+func ShardingWithDialect(dialect Dialect) ShardingOption {
+	return func(db *ShardingDB) error {
+		db.dialect = dialect
+		return nil
+	}
+}
+
+// ShardingWithFanOut 没有分片键的时候的处理策略，默认是 FanOutMerge
+func ShardingWithFanOut(policy FanOutPolicy) ShardingOption {
+	return func(db *ShardingDB) error {
+		db.fanOut = policy
+		return nil
+	}
+}
+
+// ShardingWithCrossShardWrite 是否允许涉及多个分片的写操作，默认不允许
+// 多个分片的写操作是依次执行的，没有事务保证，中途失败的时候会有部分分片已经写入
+func ShardingWithCrossShardWrite(allow bool) ShardingOption {
+	return func(db *ShardingDB) error {
+		db.crossShardWrite = allow
+		return nil
+	}
+}
+
+// ShardingWithRule 为模型配置分片规则，key 是分片键的字段名
+func ShardingWithRule(entity any, key string, algorithm ShardingAlgorithm) ShardingOption {
+	return func(db *ShardingDB) error {
+		m, err := db.r.Get(entity)
+		if err != nil {
+			return err
+		}
+		if _, ok := m.FieldMap[key]; !ok {
+			return errs.NewErrUnknownField(key)
+		}
+		db.rules[m] = &shardingRule{key: key, algorithm: algorithm}
+		return nil
+	}
+}
+
+func (s *ShardingDB) getCore() core {
+	return s.core
+}
+
+// queryContext 分库分表需要在构造 SQL 之前确定目标，所以不支持直接执行 SQL
+func (s *ShardingDB) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, errs.NewErrShardingNotSupported("直接执行 SQL")
+}
+
+func (s *ShardingDB) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, errs.NewErrShardingNotSupported("直接执行 SQL")
+}
+
+func (s *ShardingDB) rule(m *model.Model) (*shardingRule, error) {
+	rule, ok := s.rules[m]
+	if !ok {
+		return nil, errs.NewErrMissingShardingRule(m.TableName)
+	}
+	return rule, nil
+}
+
+func (s *ShardingDB) db(name string) (*DB, error) {
+	db, ok := s.dbs[name]
+	if !ok {
+		return nil, errs.NewErrUnknownShardingDB(name)
+	}
+	return db, nil
+}
+
+// route 根据查询条件计算目标，没有分片键的时候按照策略广播或者返回错误
+func (s *ShardingDB) route(m *model.Model, where []Predicate) ([]Dst, error) {
+	rule, err := s.rule(m)
+	if err != nil {
+		return nil, err
+	}
+	var dsts dstSet
+	if len(where) > 0 {
+		p := where[0]
+		for _, r := range where[1:] {
+			p = p.And(r)
+		}
+		dsts, err = rule.route(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dsts == nil {
+		if s.fanOut == FanOutReject {
+			return nil, errs.NewErrMissingShardingKey(rule.key)
+		}
+		return rule.algorithm.Broadcast(), nil
+	}
+	return dsts.sorted(), nil
+}
+
+// dstSet nil 代表没有限制，即需要广播
+type dstSet map[Dst]struct{}
+
+func (d dstSet) sorted() []Dst {
+	res := make([]Dst, 0, len(d))
+	for dst := range d {
+		res = append(res, dst)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].DB != res[j].DB {
+			return res[i].DB < res[j].DB
+		}
+		return res[i].Table < res[j].Table
+	})
+	return res
+}
+
+// route 只识别分片键上的 = 和 IN，AND 取交集，OR 取并集，其余的条件不缩小范围
+func (r *shardingRule) route(e Expression) (dstSet, error) {
+	p, ok := e.(Predicate)
+	if !ok {
+		return nil, nil
+	}
+	switch p.opt {
+	case optAND:
+		left, err := r.route(p.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := r.route(p.right)
+		if err != nil || left == nil {
+			return right, err
+		}
+		if right == nil {
+			return left, nil
+		}
+		res := dstSet{}
+		for dst := range left {
+			if _, ok := right[dst]; ok {
+				res[dst] = struct{}{}
+			}
+		}
+		return res, nil
+	case optOR:
+		left, err := r.route(p.left)
+		if err != nil || left == nil {
+			return nil, err
+		}
+		right, err := r.route(p.right)
+		if err != nil || right == nil {
+			return nil, err
+		}
+		for dst := range right {
+			left[dst] = struct{}{}
+		}
+		return left, nil
+	case optEQ, optIN:
+		col, ok := p.left.(Column)
+		if !ok || col.name != r.key || col.table != nil {
+			return nil, nil
+		}
+		var vals []any
+		switch right := p.right.(type) {
+		case value:
+			vals = []any{right.val}
+		case values:
+			vals = right.vals
+		default:
+			return nil, nil
+		}
+		res := make(dstSet, len(vals))
+		for _, val := range vals {
+			dst, err := r.algorithm.Sharding(val)
+			if err != nil {
+				return nil, err
+			}
+			res[dst] = struct{}{}
+		}
+		return res, nil
+	default:
+		return nil, nil
+	}
+}
+
+// shard 复制一个 Selector，发送到指定的分片
+// 查询经过分片所在的 DB 的中间件
+func (s *Selector[T]) shard(sess session, table string) *Selector[T] {
+	res := s.Clone()
+	res.sess = sess
+	res.ms = sess.getCore().ms
+	res.shardTable = table
+	return res
+}
+
+func shardingGetMulti[T any](ctx context.Context, sd *ShardingDB, s *Selector[T]) ([]*T, error) {
+	m, err := sd.r.Get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	dsts, err := sd.route(m, s.where)
+	if err != nil {
+		return nil, err
+	}
+	if len(dsts) == 1 {
+		db, err := sd.db(dsts[0].DB)
+		if err != nil {
+			return nil, err
+		}
+		return s.shard(db, dsts[0].Table).GetMulti(ctx)
+	}
+	subs := make([]*Selector[T], 0, len(dsts))
+	for _, dst := range dsts {
+		db, err := sd.db(dst.DB)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s.shard(db, dst.Table))
+	}
+	if len(subs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return mergeGetMulti[T](ctx, sd, m, s, subs)
+}
+
+// compareValue 比较两个相同类型的值，不支持比较的类型认为相等
+func compareValue(a, b reflect.Value) int {
+	if a.Kind() == reflect.Ptr {
+		if a.IsNil() || b.IsNil() {
+			// NULL 排在前面，和 MySQL 的行为一致
+			switch {
+			case a.IsNil() && b.IsNil():
+				return 0
+			case a.IsNil():
+				return -1
+			default:
+				return 1
+			}
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	case reflect.String:
+		return compareOrdered(a.String(), b.String())
+	}
+	if t, ok := a.Interface().(time.Time); ok {
+		return t.Compare(b.Interface().(time.Time))
+	}
+	return 0
+}
+
+func compareOrdered[V int64 | uint64 | float64 | string](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// writeShards 依次在每个分片上执行写操作，汇总影响的行数
+// 分片之间没有事务，中途失败的时候前面的分片已经写入，
+// 所以默认拒绝涉及多个分片的写操作，需要通过 ShardingWithCrossShardWrite 开启。
+// 开启之后如果中途失败，返回的错误中会列出已经写入的分片，由调用者决定如何补偿
+func (s *ShardingDB) writeShards(dsts []Dst, write func(db *DB, dst Dst) Result) Result {
+	if len(dsts) > 1 && !s.crossShardWrite {
+		return Result{err: errs.NewErrCrossShardWrite(len(dsts))}
+	}
+	dbs := make([]*DB, 0, len(dsts))
+	for _, dst := range dsts {
+		db, err := s.db(dst.DB)
+		if err != nil {
+			return Result{err: err}
+		}
+		dbs = append(dbs, db)
+	}
+	var sum sqlResult
+	done := make([]string, 0, len(dsts))
+	for i, dst := range dsts {
+		res := write(dbs[i], dst)
+		affected, err := res.RowsAffected()
+		if err != nil {
+			if len(done) > 0 {
+				err = errs.NewErrShardingPartialWrite(done, err)
+			}
+			return Result{err: err}
+		}
+		sum.rowsAffected += affected
+		done = append(done, dst.DB+"."+dst.Table)
+	}
+	return Result{res: sum}
+}
+
+// groupByShard 按照分片键的值将数据分组
+func groupByShard[T any](sd *ShardingDB, vals []*T) ([]Dst, map[Dst][]*T, error) {
+	m, err := sd.r.Get(new(T))
+	if err != nil {
+		return nil, nil, err
+	}
+	rule, err := sd.rule(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups := map[Dst][]*T{}
+	dsts := dstSet{}
+	for _, val := range vals {
+		key, err := sd.valCreator(val, m).Field(rule.key)
+		if err != nil {
+			return nil, nil, err
+		}
+		dst, err := rule.algorithm.Sharding(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		groups[dst] = append(groups[dst], val)
+		dsts[dst] = struct{}{}
+	}
+	return dsts.sorted(), groups, nil
+}
+
+func shardingInsert[T any](ctx context.Context, sd *ShardingDB, i *Inserter[T]) Result {
+	if len(i.values) == 0 {
+		return Result{err: errs.NewErrInsertZeroRow()}
+	}
+	dsts, groups, err := groupByShard(sd, i.values)
+	if err != nil {
+		return Result{err: err}
+	}
+	return sd.writeShards(dsts, func(db *DB, dst Dst) Result {
+		sub := NewInserter[T](db).Values(groups[dst]...).Cloumns(i.columns...)
+		sub.onDuplicate = i.onDuplicate
+		sub.shardTable = dst.Table
+		return sub.Exec(ctx)
+	})
+}
+
+func shardingUpdate[T any](ctx context.Context, sd *ShardingDB, u *Updater[T]) Result {
+	m, err := sd.r.Get(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	dsts, err := sd.route(m, u.where)
+	if err != nil {
+		return Result{err: err}
+	}
+	return sd.writeShards(dsts, func(db *DB, dst Dst) Result {
+		sub := *u
+		sub.builder.reset()
+		sub.sess = db
+		sub.shardTable = dst.Table
+		return sub.Exec(ctx)
+	})
+}
+
+func shardingDelete[T any](ctx context.Context, sd *ShardingDB, d *Deleter[T]) Result {
+	m, err := sd.r.Get(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	dsts, err := sd.route(m, d.where)
+	if err != nil {
+		return Result{err: err}
+	}
+	return sd.writeShards(dsts, func(db *DB, dst Dst) Result {
+		sub := *d
+		sub.builder.reset()
+		sub.sess = db
+		sub.shardTable = dst.Table
+		return sub.Exec(ctx)
+	})
+}
+
+// shardingBulkUpdate 和插入一样，按照每一行的分片键分组
+func shardingBulkUpdate[T any](ctx context.Context, sd *ShardingDB, u *BulkUpdater[T]) Result {
+	if len(u.values) == 0 {
+		return Result{err: errs.NewErrUpdateZeroRow()}
+	}
+	dsts, groups, err := groupByShard(sd, u.values)
+	if err != nil {
+		return Result{err: err}
+	}
+	return sd.writeShards(dsts, func(db *DB, dst Dst) Result {
+		sub := NewBulkUpdater[T](db).Values(groups[dst]...).Columns(u.columns...)
+		sub.shardTable = dst.Table
+		return sub.Exec(ctx)
+	})
+}
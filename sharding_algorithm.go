@@ -0,0 +1,223 @@
+package morm
+
+import (
+	"fmt"
+	"github.com/NotFound1911/morm/errors"
+	"hash/fnv"
+	"reflect"
+	"time"
+)
+
+// Dst 分片的目标，DB 是 ShardingDB 中注册的库名，Table 是表名
+type Dst struct {
+	DB    string
+	Table string
+}
+
+// ShardingAlgorithm 分片算法
+type ShardingAlgorithm interface {
+	// Sharding 根据分片键的值计算目标
+	Sharding(val any) (Dst, error)
+	// Broadcast 返回所有的目标，用于没有分片键的查询
+	Broadcast() []Dst
+}
+
+var _ ShardingAlgorithm = &HashSharding{}
+
+// HashSharding 哈希取模，整数直接取模，字符串先计算 FNV 哈希
+// 库的下标是 hash % DBCount，表的下标是 hash / DBCount % TableCount，
+// 这样同一个库里面的数据也会均匀分布到每一张表
+type HashSharding struct {
+	// DBPattern 库名，例如 order_db_%d
+	DBPattern string
+	DBCount   int
+	// TablePattern 表名，例如 order_tab_%d
+	TablePattern string
+	TableCount   int
+}
+
+func (h *HashSharding) Sharding(val any) (Dst, error) {
+	hash, err := shardingHash(val)
+	if err != nil {
+		return Dst{}, err
+	}
+	dbCnt, tabCnt := uint64(max1(h.DBCount)), uint64(max1(h.TableCount))
+	return Dst{
+		DB:    fmt.Sprintf(h.DBPattern, hash%dbCnt),
+		Table: fmt.Sprintf(h.TablePattern, hash/dbCnt%tabCnt),
+	}, nil
+}
+
+func (h *HashSharding) Broadcast() []Dst {
+	res := make([]Dst, 0, max1(h.DBCount)*max1(h.TableCount))
+	for i := 0; i < max1(h.DBCount); i++ {
+		for j := 0; j < max1(h.TableCount); j++ {
+			res = append(res, Dst{
+				DB:    fmt.Sprintf(h.DBPattern, i),
+				Table: fmt.Sprintf(h.TablePattern, j),
+			})
+		}
+	}
+	return res
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func shardingHash(val any) (uint64, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i < 0 {
+			i = -i
+		}
+		return uint64(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.String:
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(rv.String()))
+		return h.Sum64(), nil
+	default:
+		return 0, errs.NewErrInvalidShardingValue(val)
+	}
+}
+
+var _ ShardingAlgorithm = &RangeSharding{}
+
+// ShardRange 分片键在 [Start, End) 范围内的数据放在 Dst
+type ShardRange struct {
+	Start int64
+	End   int64
+	Dst   Dst
+}
+
+// RangeSharding 按照整数范围分片，例如按照用户 ID 每一千万一张表
+type RangeSharding struct {
+	Ranges []ShardRange
+}
+
+func (r *RangeSharding) Sharding(val any) (Dst, error) {
+	rv := reflect.ValueOf(val)
+	var key int64
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		key = rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		key = int64(rv.Uint())
+	default:
+		return Dst{}, errs.NewErrInvalidShardingValue(val)
+	}
+	for _, rg := range r.Ranges {
+		if key >= rg.Start && key < rg.End {
+			return rg.Dst, nil
+		}
+	}
+	return Dst{}, errs.NewErrInvalidShardingValue(val)
+}
+
+func (r *RangeSharding) Broadcast() []Dst {
+	res := make([]Dst, 0, len(r.Ranges))
+	seen := make(map[Dst]struct{}, len(r.Ranges))
+	for _, rg := range r.Ranges {
+		if _, ok := seen[rg.Dst]; ok {
+			continue
+		}
+		seen[rg.Dst] = struct{}{}
+		res = append(res, rg.Dst)
+	}
+	return res
+}
+
+// DateStep 按照日期分片的粒度
+type DateStep int
+
+const (
+	DateStepDay DateStep = iota
+	DateStepMonth
+	DateStepYear
+)
+
+var _ ShardingAlgorithm = &DateSharding{}
+
+// DateSharding 按照日期分片，例如按年分库、按月分表
+// 库名和表名分别是 DBPattern 和 TablePattern 用对应的 Layout 格式化日期之后得到的，
+// Layout 为空的时候 Pattern 会原样作为名字
+type DateSharding struct {
+	DBPattern    string
+	DBLayout     string
+	TablePattern string
+	TableLayout  string
+	// Start 和 End 数据的时间范围，用于广播
+	Start time.Time
+	End   time.Time
+	// Step 表的粒度，用于广播
+	Step DateStep
+}
+
+func (d *DateSharding) Sharding(val any) (Dst, error) {
+	var t time.Time
+	switch v := val.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return Dst{}, errs.NewErrInvalidShardingValue(val)
+		}
+		t = *v
+	default:
+		return Dst{}, errs.NewErrInvalidShardingValue(val)
+	}
+	return d.dst(t), nil
+}
+
+func (d *DateSharding) dst(t time.Time) Dst {
+	return Dst{
+		DB:    formatDate(d.DBPattern, d.DBLayout, t),
+		Table: formatDate(d.TablePattern, d.TableLayout, t),
+	}
+}
+
+func formatDate(pattern string, layout string, t time.Time) string {
+	if layout == "" {
+		return pattern
+	}
+	return fmt.Sprintf(pattern, t.Format(layout))
+}
+
+func (d *DateSharding) Broadcast() []Dst {
+	res := make([]Dst, 0, 16)
+	seen := make(map[Dst]struct{}, 16)
+	for t := d.Start; !t.After(d.End); t = d.next(t) {
+		dst := d.dst(t)
+		if _, ok := seen[dst]; ok {
+			continue
+		}
+		seen[dst] = struct{}{}
+		res = append(res, dst)
+	}
+	// End 所在的分片，避免 Start 不是分片起点的时候漏掉最后一个
+	if dst := d.dst(d.End); !d.End.Before(d.Start) {
+		if _, ok := seen[dst]; !ok {
+			res = append(res, dst)
+		}
+	}
+	return res
+}
+
+func (d *DateSharding) next(t time.Time) time.Time {
+	switch d.Step {
+	case DateStepYear:
+		return time.Date(t.Year()+1, 1, 1, 0, 0, 0, 0, t.Location())
+	case DateStepMonth:
+		// 避免 1 月 31 日加一个月变成 3 月
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,586 @@
+package morm
+
+import (
+	"container/heap"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/NotFound1911/morm/model"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultMaxConcurrency 广播查询的时候，默认最多同时查询的分片数量
+const defaultMaxConcurrency = 8
+
+// ShardingWithMaxConcurrency 广播查询的时候最多同时查询的分片数量
+func ShardingWithMaxConcurrency(n int) ShardingOption {
+	return func(db *ShardingDB) error {
+		db.maxConcurrency = n
+		return nil
+	}
+}
+
+// runAll 用有限数量的 goroutine 执行 fn，返回第一个错误
+// 出现错误之后调用 cancel 取消其它还在执行的查询，并且不再开始新的查询
+// cancel 应该取消 ctx，ctx 由调用者创建，因为查询返回的 *sql.Rows 在 ctx 取消之后就不能再读取了
+func (s *ShardingDB) runAll(ctx context.Context, cancel context.CancelFunc, n int,
+	fn func(ctx context.Context, i int) error) error {
+	limit := s.maxConcurrency
+	if limit <= 0 {
+		limit = defaultMaxConcurrency
+	}
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		tokens   = make(chan struct{}, limit)
+	)
+	for i := 0; i < n; i++ {
+		tokens <- struct{}{}
+		if ctx.Err() != nil {
+			<-tokens
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			if err := fn(ctx, i); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr == nil {
+		// 调用者的 ctx 被取消
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// mergeRow 一行数据，每一列都是按照字段类型扫描出来的值
+type mergeRow []reflect.Value
+
+// mergeColumn 结果集中的一列
+type mergeColumn struct {
+	name string
+	// field 对应的字段，隐藏列为 nil
+	field *model.Field
+	// agg 聚合函数，为空代表是普通的列，在有聚合的时候也就是分组的列
+	agg string
+	// cnt AVG 改写之后对应的 COUNT 列的下标
+	cnt int
+}
+
+// typ 扫描使用的类型，聚合函数在没有数据的时候会返回 NULL，所以使用指针
+// SUM 和 COUNT 在合并的时候可能超出字段类型的范围，所以使用 int64、uint64 或者 float64，
+// AVG 改写之后的 SUM 使用 float64，最后才转换为字段的类型
+func (c mergeColumn) typ() reflect.Type {
+	if c.field == nil {
+		return reflect.TypeOf(int64(0))
+	}
+	switch c.agg {
+	case "":
+		return c.field.Type
+	case "SUM", "COUNT":
+		return reflect.PtrTo(wideType(c.field.Type))
+	case "AVG":
+		return reflect.TypeOf((*float64)(nil))
+	default:
+		return reflect.PtrTo(c.field.Type)
+	}
+}
+
+func isNumeric(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func wideType(typ reflect.Type) reflect.Type {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.TypeOf(int64(0))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.TypeOf(uint64(0))
+	case reflect.Float32, reflect.Float64:
+		return reflect.TypeOf(float64(0))
+	default:
+		return typ
+	}
+}
+
+func scanRow(rows *sql.Rows, cols []mergeColumn) (mergeRow, error) {
+	dest := make([]any, len(cols))
+	res := make(mergeRow, len(cols))
+	for i, col := range cols {
+		val := reflect.New(col.typ())
+		dest[i] = val.Interface()
+		res[i] = val.Elem()
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// toEntity 将合并之后的一行转化为 T，隐藏列会被忽略
+func toEntity[T any](cols []mergeColumn, row mergeRow) *T {
+	res := new(T)
+	val := reflect.ValueOf(res).Elem()
+	for i, col := range cols {
+		if col.field == nil {
+			continue
+		}
+		v := row[i]
+		if col.agg != "" {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem().Convert(col.field.Type)
+		}
+		val.FieldByName(col.field.GoName).Set(v)
+	}
+	return res
+}
+
+// mergeOrder 排序列在结果集中的下标
+type mergeOrder struct {
+	idx  int
+	desc bool
+}
+
+func mergeOrders(m *model.Model, cols []mergeColumn, orderBys []OrderBy) ([]mergeOrder, error) {
+	res := make([]mergeOrder, 0, len(orderBys))
+	for _, ob := range orderBys {
+		var name string
+		switch exp := ob.expr.(type) {
+		case Column:
+			fd, ok := m.FieldMap[exp.name]
+			if !ok {
+				return nil, errs.NewErrUnknownField(exp.name)
+			}
+			name = fd.ColName
+		case AliasExpr:
+			name = exp.alias
+		default:
+			return nil, errs.NewErrShardingNotSupported("跨分片按照表达式排序")
+		}
+		idx := -1
+		for i, col := range cols {
+			if col.field != nil && col.name == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, errs.NewErrShardingNotSupported("跨分片按照不在结果集中的列排序")
+		}
+		res = append(res, mergeOrder{idx: idx, desc: ob.fun == "DESC"})
+	}
+	return res, nil
+}
+
+func compareRow(orders []mergeOrder, a, b mergeRow) int {
+	for _, o := range orders {
+		c := compareValue(a[o.idx], b[o.idx])
+		if c == 0 {
+			continue
+		}
+		if o.desc {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
+
+// mergeGetMulti 广播查询，并且合并所有分片的结果
+// 没有聚合的时候，每个分片按照 ORDER BY 排好序返回前 offset + limit 行，然后在内存中做 k 路归并；
+// 有聚合的时候，每个分片返回所有分组，在内存中合并分组，AVG 被改写为 SUM 和 COUNT
+func mergeGetMulti[T any](ctx context.Context, sd *ShardingDB, m *model.Model, s *Selector[T], subs []*Selector[T]) ([]*T, error) {
+	if len(s.having) > 0 {
+		return nil, errs.NewErrShardingNotSupported("跨分片的 HAVING")
+	}
+	if s.distinct {
+		// 每个分片只能各自去重，相同的行在多个分片上都存在的时候结果会重复
+		return nil, errs.NewErrShardingNotSupported("跨分片的 DISTINCT")
+	}
+	aggregated := len(s.groupBys) > 0
+	for _, col := range s.columns {
+		if _, ok := col.(Aggregate); ok {
+			aggregated = true
+		}
+	}
+	if aggregated {
+		return mergeAggregate(ctx, sd, m, s, subs)
+	}
+	return mergeSorted(ctx, sd, m, s, subs)
+}
+
+func mergeSorted[T any](ctx context.Context, sd *ShardingDB, m *model.Model, s *Selector[T], subs []*Selector[T]) ([]*T, error) {
+	for _, sub := range subs {
+		if s.limit > 0 {
+			sub.limit = s.limit + s.offset
+		}
+		sub.offset = 0
+	}
+	rowsList := make([]*sql.Rows, len(subs))
+	defer func() {
+		for _, rows := range rowsList {
+			if rows != nil {
+				_ = rows.Close()
+			}
+		}
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := sd.runAll(ctx, cancel, len(subs), func(ctx context.Context, i int) error {
+		var err error
+		rowsList[i], err = queryRows(ctx, subs[i].core, subs[i].sess, &QueryContext{
+			Builder: subs[i],
+			Type:    "SELECT",
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	names, err := rowsList[0].Columns()
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]mergeColumn, 0, len(names))
+	for _, name := range names {
+		fd, ok := m.ColumnMap[name]
+		if !ok {
+			return nil, errs.NewErrUnknownField(name)
+		}
+		cols = append(cols, mergeColumn{name: name, field: fd})
+	}
+	orders, err := mergeOrders(m, cols, s.orderBys)
+	if err != nil {
+		return nil, err
+	}
+	h := &cursorHeap{orders: orders}
+	for i, rows := range rowsList {
+		c := &cursor{rows: rows, idx: i}
+		ok, err := c.next(cols)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			h.cursors = append(h.cursors, c)
+		}
+	}
+	heap.Init(h)
+	res := make([]*T, 0, 16)
+	for skipped := 0; h.Len() > 0; {
+		if s.limit > 0 && len(res) >= s.limit {
+			break
+		}
+		c := h.cursors[0]
+		if skipped < s.offset {
+			skipped++
+		} else {
+			res = append(res, toEntity[T](cols, c.cur))
+		}
+		ok, err := c.next(cols)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	if len(res) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return res, nil
+}
+
+// cursor 指向一个分片结果集的当前行
+type cursor struct {
+	rows *sql.Rows
+	cur  mergeRow
+	// idx 分片的下标，排序的值相同的时候按照分片的顺序输出
+	idx int
+}
+
+func (c *cursor) next(cols []mergeColumn) (bool, error) {
+	if !c.rows.Next() {
+		return false, c.rows.Err()
+	}
+	row, err := scanRow(c.rows, cols)
+	if err != nil {
+		return false, err
+	}
+	c.cur = row
+	return true, nil
+}
+
+type cursorHeap struct {
+	cursors []*cursor
+	orders  []mergeOrder
+}
+
+func (h *cursorHeap) Len() int {
+	return len(h.cursors)
+}
+
+func (h *cursorHeap) Less(i, j int) bool {
+	if c := compareRow(h.orders, h.cursors[i].cur, h.cursors[j].cur); c != 0 {
+		return c < 0
+	}
+	return h.cursors[i].idx < h.cursors[j].idx
+}
+
+func (h *cursorHeap) Swap(i, j int) {
+	h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i]
+}
+
+func (h *cursorHeap) Push(x any) {
+	h.cursors = append(h.cursors, x.(*cursor))
+}
+
+func (h *cursorHeap) Pop() any {
+	n := len(h.cursors)
+	res := h.cursors[n-1]
+	h.cursors = h.cursors[:n-1]
+	return res
+}
+
+// aggregateColumns 改写查询的列，返回改写之后的列以及结果集的结构
+func aggregateColumns(m *model.Model, columns []Selectable) ([]Selectable, []mergeColumn, error) {
+	if len(columns) == 0 {
+		return nil, nil, errs.NewErrShardingNotSupported("跨分片的聚合查询不指定列")
+	}
+	selects := make([]Selectable, 0, len(columns))
+	cols := make([]mergeColumn, 0, len(columns))
+	var hidden []Selectable
+	for _, c := range columns {
+		switch col := c.(type) {
+		case Column:
+			fd, ok := m.FieldMap[col.name]
+			if !ok {
+				return nil, nil, errs.NewErrUnknownField(col.name)
+			}
+			name := fd.ColName
+			if col.alias != "" {
+				name = col.alias
+				if fd, ok = m.ColumnMap[name]; !ok {
+					return nil, nil, errs.NewErrUnknownField(name)
+				}
+			}
+			selects = append(selects, col)
+			cols = append(cols, mergeColumn{name: name, field: fd})
+		case Aggregate:
+			if col.distinct {
+				return nil, nil, errs.NewErrShardingNotSupported("跨分片的 DISTINCT 聚合")
+			}
+			fd, ok := m.ColumnMap[col.alias]
+			if !ok {
+				return nil, nil, errs.NewErrUnknownField(col.alias)
+			}
+			mc := mergeColumn{name: col.alias, field: fd, agg: col.fn}
+			switch col.fn {
+			case "SUM", "COUNT", "MAX", "MIN":
+				selects = append(selects, col)
+			case "AVG":
+				if !isNumeric(fd.Type) {
+					return nil, nil, errs.NewErrShardingNotSupported("跨分片对非数字字段 " + fd.GoName + " 求平均值")
+				}
+				// AVG 改写为 SUM 和 COUNT，COUNT 作为隐藏列放在最后
+				sum, cnt := col, col
+				sum.fn = "SUM"
+				cnt.fn = "COUNT"
+				cnt.alias = fmt.Sprintf("morm_avg_cnt_%d", len(hidden))
+				selects = append(selects, sum)
+				hidden = append(hidden, cnt)
+				mc.cnt = len(columns) + len(hidden) - 1
+			default:
+				return nil, nil, errs.NewErrShardingNotSupported("跨分片的 " + col.fn)
+			}
+			cols = append(cols, mc)
+		default:
+			return nil, nil, errs.NewErrShardingNotSupported("跨分片的聚合查询使用表达式")
+		}
+	}
+	for _, h := range hidden {
+		selects = append(selects, h)
+		cols = append(cols, mergeColumn{name: h.selectedAlias()})
+	}
+	return selects, cols, nil
+}
+
+func mergeAggregate[T any](ctx context.Context, sd *ShardingDB, m *model.Model, s *Selector[T], subs []*Selector[T]) ([]*T, error) {
+	selects, cols, err := aggregateColumns(m, s.columns)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := mergeOrders(m, cols, s.orderBys)
+	if err != nil {
+		return nil, err
+	}
+	// 每个分片返回所有的分组
+	for _, sub := range subs {
+		sub.columns = selects
+		sub.orderBys = nil
+		sub.limit = 0
+		sub.offset = 0
+	}
+	parts := make([][]mergeRow, len(subs))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err = sd.runAll(ctx, cancel, len(subs), func(ctx context.Context, i int) error {
+		rows, err := queryRows(ctx, subs[i].core, subs[i].sess, &QueryContext{
+			Builder: subs[i],
+			Type:    "SELECT",
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			row, err := scanRow(rows, cols)
+			if err != nil {
+				return err
+			}
+			parts[i] = append(parts[i], row)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 按照分组的列合并
+	groups := make([]mergeRow, 0, 16)
+	index := map[string]int{}
+	for _, part := range parts {
+		for _, row := range part {
+			key := groupKey(cols, row)
+			idx, ok := index[key]
+			if !ok {
+				index[key] = len(groups)
+				groups = append(groups, row)
+				continue
+			}
+			mergeAggregateRow(cols, groups[idx], row)
+		}
+	}
+	for _, row := range groups {
+		finishAvg(cols, row)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return compareRow(orders, groups[i], groups[j]) < 0
+	})
+	if s.offset >= len(groups) {
+		return nil, sql.ErrNoRows
+	}
+	groups = groups[s.offset:]
+	if s.limit > 0 && s.limit < len(groups) {
+		groups = groups[:s.limit]
+	}
+	res := make([]*T, 0, len(groups))
+	for _, row := range groups {
+		res = append(res, toEntity[T](cols, row))
+	}
+	return res, nil
+}
+
+func groupKey(cols []mergeColumn, row mergeRow) string {
+	var sb strings.Builder
+	for i, col := range cols {
+		if col.agg != "" || col.field == nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%#v", groupValue(row[i])))
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// groupValue 分组的值，指针会被解引用，实现了 driver.Valuer 的使用 Value 的结果，
+// 不然不同分片的同一个分组会因为指针地址不同而无法合并
+func groupValue(val reflect.Value) any {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	res := val.Interface()
+	if valuer, ok := res.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			return v
+		}
+	}
+	return res
+}
+
+// mergeAggregateRow 将 src 合并到 dst 上
+func mergeAggregateRow(cols []mergeColumn, dst, src mergeRow) {
+	for i, col := range cols {
+		switch {
+		case col.field == nil:
+			// 隐藏的 COUNT 列
+			dst[i].SetInt(dst[i].Int() + src[i].Int())
+		case col.agg == "":
+		case src[i].IsNil():
+		case dst[i].IsNil():
+			dst[i].Set(src[i])
+		case col.agg == "SUM" || col.agg == "COUNT" || col.agg == "AVG":
+			addValue(dst[i].Elem(), src[i].Elem())
+		case col.agg == "MAX":
+			if compareValue(src[i].Elem(), dst[i].Elem()) > 0 {
+				dst[i].Set(src[i])
+			}
+		case col.agg == "MIN":
+			if compareValue(src[i].Elem(), dst[i].Elem()) < 0 {
+				dst[i].Set(src[i])
+			}
+		}
+	}
+}
+
+func addValue(dst, src reflect.Value) {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst.SetInt(dst.Int() + src.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		dst.SetUint(dst.Uint() + src.Uint())
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(dst.Float() + src.Float())
+	}
+}
+
+// finishAvg 用合并之后的 SUM 除以 COUNT 得到 AVG，转换为字段的类型在 toEntity 中进行
+func finishAvg(cols []mergeColumn, row mergeRow) {
+	for i, col := range cols {
+		if col.agg != "AVG" || row[i].IsNil() {
+			continue
+		}
+		cnt := row[col.cnt].Int()
+		if cnt == 0 {
+			continue
+		}
+		val := row[i].Elem()
+		val.SetFloat(val.Float() / float64(cnt))
+	}
+}
@@ -0,0 +1,322 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/NotFound1911/morm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardingDB_Aggregate(t *testing.T) {
+	sd, _ := newShardingDB(t, "sharding_agg", ShardingWithCrossShardWrite(true))
+	ctx := context.Background()
+	// 1 和 5 在同一个分片，所以每个分片求平均值再平均是错误的结果
+	res := NewInserter[ShardingOrder](sd).Values(
+		&ShardingOrder{Id: 1, UserId: 1, Amount: 10},
+		&ShardingOrder{Id: 2, UserId: 2, Amount: 10},
+		&ShardingOrder{Id: 3, UserId: 3, Amount: 10},
+		&ShardingOrder{Id: 4, UserId: 4, Amount: 10},
+		&ShardingOrder{Id: 5, UserId: 5, Amount: 100},
+	).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	testCases := []struct {
+		name    string
+		s       *Selector[ShardingOrder]
+		want    []*ShardingOrder
+		wantErr error
+	}{
+		{
+			name: "count max avg",
+			s: NewSelector[ShardingOrder](sd).
+				Select(Count("Id").As("id"), Max("UserId").As("user_id"), Avg("Amount").As("amount")),
+			want: []*ShardingOrder{{Id: 5, UserId: 5, Amount: 28}},
+		},
+		{
+			name: "sum",
+			s:    NewSelector[ShardingOrder](sd).Select(Sum("Amount").As("amount")),
+			want: []*ShardingOrder{{Amount: 140}},
+		},
+		{
+			name: "group by",
+			s: NewSelector[ShardingOrder](sd).
+				Select(C("Amount"), Count("Id").As("id"), Max("UserId").As("user_id")).
+				GroupBy(C("Amount")).OrderBy(Desc("Amount")),
+			want: []*ShardingOrder{
+				{Id: 1, UserId: 5, Amount: 100},
+				{Id: 4, UserId: 4, Amount: 10},
+			},
+		},
+		{
+			name: "group by order by aggregate",
+			s: NewSelector[ShardingOrder](sd).
+				Select(C("Amount"), Count("Id").As("id")).
+				GroupBy(C("Amount")).OrderBy(Desc("Id")).Limit(1),
+			want: []*ShardingOrder{{Id: 4, Amount: 10}},
+		},
+		{
+			name: "group by limit offset",
+			s: NewSelector[ShardingOrder](sd).
+				Select(C("Amount"), Min("UserId").As("user_id")).
+				GroupBy(C("Amount")).OrderBy(Asc("Amount")).Limit(1).Offset(1),
+			want: []*ShardingOrder{{UserId: 5, Amount: 100}},
+		},
+		{
+			name:    "group by without columns",
+			s:       NewSelector[ShardingOrder](sd).GroupBy(C("Amount")),
+			wantErr: errs.NewErrShardingNotSupported("跨分片的聚合查询不指定列"),
+		},
+		{
+			name:    "count distinct",
+			s:       NewSelector[ShardingOrder](sd).Select(CountDistinct("Amount").As("amount")),
+			wantErr: errs.NewErrShardingNotSupported("跨分片的 DISTINCT 聚合"),
+		},
+		{
+			name:    "order by column not selected",
+			s:       NewSelector[ShardingOrder](sd).Select(Sum("Amount").As("amount")).OrderBy(Asc("Id")),
+			wantErr: errs.NewErrShardingNotSupported("跨分片按照不在结果集中的列排序"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.s.GetMulti(ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// ShardingScore 字段是 int8，合并的时候 SUM 和 AVG 的中间结果都会超出 int8 的范围
+type ShardingScore struct {
+	Id     int64
+	UserId int64
+	Score  int8
+}
+
+func TestShardingDB_AggregateOverflow(t *testing.T) {
+	dbs := make(map[string]*DB, 2)
+	for i := 0; i < 2; i++ {
+		db := memoryDBWithDB(fmt.Sprintf("sharding_score_db_%d", i), t, DBWithDialect(SQLite3))
+		_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS sharding_score_0(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    score INTEGER NOT NULL
+)`)
+		require.NoError(t, err)
+		dbs[fmt.Sprintf("score_db_%d", i)] = db
+	}
+	sd, err := NewShardingDB(dbs, ShardingWithDialect(SQLite3), ShardingWithCrossShardWrite(true),
+		ShardingWithRule(&ShardingScore{}, "UserId", &HashSharding{DBPattern: "score_db_%d", DBCount: 2, TablePattern: "sharding_score_%d", TableCount: 1}))
+	require.NoError(t, err)
+	ctx := context.Background()
+	// 总和是 101 * 4 = 404，平均值是 101，分片 0 的平均值是 100.5
+	res := NewInserter[ShardingScore](sd).Values(
+		&ShardingScore{Id: 1, UserId: 2, Score: 100},
+		&ShardingScore{Id: 2, UserId: 4, Score: 101},
+		&ShardingScore{Id: 3, UserId: 1, Score: 101},
+		&ShardingScore{Id: 4, UserId: 3, Score: 102},
+	).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	got, err := NewSelector[ShardingScore](sd).Select(Avg("Score").As("score"), Count("Id").As("id")).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*ShardingScore{{Id: 4, Score: 101}}, got)
+
+	got, err = NewSelector[ShardingScore](sd).Select(Sum("Score").As("user_id")).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*ShardingScore{{UserId: 404}}, got)
+}
+
+func TestShardingDB_MergeSession(t *testing.T) {
+	var queries []string
+	dbs := make(map[string]*DB, 2)
+	mocks := make([]sqlmock.Sqlmock, 0, 2)
+	for i := 0; i < 2; i++ {
+		primary, primaryMock := newMockDB(t)
+		replica, _ := newMockDB(t)
+		db, err := OpenDB(primary, DBWithDialect(SQLite3),
+			DBWithReplicas(RoundRobin(), Replica{DB: replica}),
+			DBWithMiddleware(func(next HanderFunc) HanderFunc {
+				return func(ctx context.Context, qc *QueryContext) *QueryResult {
+					q, err := qc.Query()
+					if err != nil {
+						return &QueryResult{Err: err}
+					}
+					queries = append(queries, q.SQL)
+					return next(ctx, qc)
+				}
+			}))
+		require.NoError(t, err)
+		dbs[fmt.Sprintf("order_db_%d", i)] = db
+		mocks = append(mocks, primaryMock)
+	}
+	sd, err := NewShardingDB(dbs, ShardingWithDialect(SQLite3), ShardingWithMaxConcurrency(1),
+		ShardingWithRule(&ShardingOrder{}, "UserId", &HashSharding{
+			DBPattern:    "order_db_%d",
+			DBCount:      2,
+			TablePattern: "order_tab_%d",
+			TableCount:   1,
+		}))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// UsePrimary 的广播查询使用每个分库的主库，并且经过分库的中间件
+	for i, mock := range mocks {
+		mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount"}).
+			AddRow(int64(i+1), int64(i), int64(10)))
+	}
+	got, err := NewSelector[ShardingOrder](sd).OrderBy(Asc("Id")).UsePrimary().GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*ShardingOrder{{Id: 1, UserId: 0, Amount: 10}, {Id: 2, UserId: 1, Amount: 10}}, got)
+	for _, mock := range mocks {
+		mock.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"amount"}).AddRow(int64(10)))
+	}
+	got, err = NewSelector[ShardingOrder](sd).Select(Sum("Amount").As("amount")).GetMulti(UsePrimary(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, []*ShardingOrder{{Amount: 20}}, got)
+	mocks[1].ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(3)))
+	o, err := NewSelector[ShardingOrder](sd).Where(C("UserId").EQ(int64(1))).UsePrimary().Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), o.Id)
+	assert.Equal(t, []string{
+		"SELECT * FROM `order_tab_0` ORDER BY `id` ASC;",
+		"SELECT * FROM `order_tab_0` ORDER BY `id` ASC;",
+		"SELECT SUM(`amount`) AS `amount` FROM `order_tab_0`;",
+		"SELECT SUM(`amount`) AS `amount` FROM `order_tab_0`;",
+		"SELECT * FROM `order_tab_0` WHERE `user_id` = ? LIMIT ?;",
+	}, queries)
+	for _, mock := range mocks {
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+
+	// 分库分表没有事务，锁定读直接返回错误
+	_, err = NewSelector[ShardingOrder](sd).ForUpdate().GetMulti(ctx)
+	assert.Equal(t, errs.NewErrLockOutsideTx("FOR UPDATE"), err)
+	_, err = NewSelector[ShardingOrder](sd).Where(C("UserId").EQ(int64(1))).ForUpdate().Get(ctx)
+	assert.Equal(t, errs.NewErrLockOutsideTx("FOR UPDATE"), err)
+}
+
+func TestGroupKey(t *testing.T) {
+	cols := []mergeColumn{{name: "name", field: &model.Field{}}, {name: "cnt", field: &model.Field{}, agg: "COUNT"}}
+	row := func(vals ...any) mergeRow {
+		res := make(mergeRow, 0, len(vals))
+		for _, val := range vals {
+			res = append(res, reflect.ValueOf(val))
+		}
+		return res
+	}
+	str := func(s string) *string {
+		return &s
+	}
+	null := func(s string, valid bool) *sql.NullString {
+		return &sql.NullString{String: s, Valid: valid}
+	}
+	cnt := int64(1)
+	// 不同分片扫描出来的指针地址不同
+	assert.Equal(t, groupKey(cols, row(str("Tom"), &cnt)), groupKey(cols, row(str("Tom"), &cnt)))
+	assert.NotEqual(t, groupKey(cols, row(str("Tom"), &cnt)), groupKey(cols, row(str("Jerry"), &cnt)))
+	assert.NotEqual(t, groupKey(cols, row(str(""), &cnt)), groupKey(cols, row((*string)(nil), &cnt)))
+	assert.Equal(t, groupKey(cols, row(null("Tom", true), &cnt)), groupKey(cols, row(null("Tom", true), &cnt)))
+	// 无效的 NullString 都是 NULL
+	assert.Equal(t, groupKey(cols, row(null("a", false), &cnt)), groupKey(cols, row(null("", false), &cnt)))
+	assert.Equal(t, groupKey(cols, row(null("", false), &cnt)), groupKey(cols, row((*sql.NullString)(nil), &cnt)))
+}
+
+func TestShardingDB_Merge(t *testing.T) {
+	sd, _ := newShardingDB(t, "sharding_merge", ShardingWithMaxConcurrency(2), ShardingWithCrossShardWrite(true))
+	ctx := context.Background()
+	orders := make([]*ShardingOrder, 0, 20)
+	for i := int64(1); i <= 20; i++ {
+		// 金额和 Id 的顺序不一致，并且有重复的金额
+		orders = append(orders, &ShardingOrder{Id: i, UserId: i, Amount: (i * 7) % 11})
+	}
+	res := NewInserter[ShardingOrder](sd).Values(orders...).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	got, err := NewSelector[ShardingOrder](sd).OrderBy(Desc("Amount"), Asc("Id")).GetMulti(ctx)
+	require.NoError(t, err)
+	require.Len(t, got, 20)
+	for i := 1; i < len(got); i++ {
+		prev, cur := got[i-1], got[i]
+		assert.True(t, prev.Amount > cur.Amount || (prev.Amount == cur.Amount && prev.Id < cur.Id))
+	}
+
+	page, err := NewSelector[ShardingOrder](sd).OrderBy(Desc("Amount"), Asc("Id")).Limit(5).Offset(7).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, got[7:12], page)
+
+	page, err = NewSelector[ShardingOrder](sd).Select(C("Id"), C("Amount")).
+		OrderBy(Desc("Amount"), Asc("Id")).Limit(3).GetMulti(ctx)
+	require.NoError(t, err)
+	for i, o := range page {
+		assert.Equal(t, &ShardingOrder{Id: got[i].Id, Amount: got[i].Amount}, o)
+	}
+
+	_, err = NewSelector[ShardingOrder](sd).Select(C("Id")).OrderBy(Asc("Amount")).GetMulti(ctx)
+	assert.Equal(t, errs.NewErrShardingNotSupported("跨分片按照不在结果集中的列排序"), err)
+}
+
+func TestShardingDB_runAll(t *testing.T) {
+	sd, _ := newShardingDB(t, "sharding_run_all", ShardingWithMaxConcurrency(2))
+	var (
+		running atomic.Int64
+		maxRun  atomic.Int64
+		mu      sync.Mutex
+		done    []int
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := sd.runAll(ctx, cancel, 6, func(ctx context.Context, i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRun.Load()
+			if n <= m || maxRun.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		done = append(done, i)
+		mu.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), maxRun.Load())
+	assert.Len(t, done, 6)
+
+	// 第一个错误之后取消其它的查询，并且不再开始新的查询
+	wantErr := errors.New("mock error")
+	var started, canceled atomic.Int64
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = sd.runAll(ctx, cancel, 6, func(ctx context.Context, i int) error {
+		started.Add(1)
+		if i == 0 {
+			return wantErr
+		}
+		select {
+		case <-ctx.Done():
+			canceled.Add(1)
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	assert.Equal(t, wantErr, err)
+	assert.Equal(t, int64(2), started.Load())
+	assert.Equal(t, int64(1), canceled.Load())
+}
@@ -0,0 +1,403 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type ShardingOrder struct {
+	Id     int64
+	UserId int64
+	Amount int64
+}
+
+func shardingOrderCreateSQL(table string) string {
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL
+)
+`, table)
+}
+
+// newShardingDB 两个库，每个库两张表，按照 UserId 哈希分片
+func newShardingDB(t *testing.T, name string, opts ...ShardingOption) (*ShardingDB, map[string]*DB) {
+	dbs := make(map[string]*DB, 2)
+	for i := 0; i < 2; i++ {
+		db := memoryDBWithDB(fmt.Sprintf("%s_db_%d", name, i), t, DBWithDialect(SQLite3))
+		for j := 0; j < 2; j++ {
+			_, err := db.db.Exec(shardingOrderCreateSQL(fmt.Sprintf("order_tab_%d", j)))
+			require.NoError(t, err)
+		}
+		dbs[fmt.Sprintf("order_db_%d", i)] = db
+	}
+	opts = append([]ShardingOption{
+		ShardingWithDialect(SQLite3),
+		ShardingWithRule(&ShardingOrder{}, "UserId", &HashSharding{
+			DBPattern:    "order_db_%d",
+			DBCount:      2,
+			TablePattern: "order_tab_%d",
+			TableCount:   2,
+		}),
+	}, opts...)
+	sd, err := NewShardingDB(dbs, opts...)
+	require.NoError(t, err)
+	return sd, dbs
+}
+
+func TestShardingDB(t *testing.T) {
+	sd, dbs := newShardingDB(t, "sharding", ShardingWithCrossShardWrite(true))
+	ctx := context.Background()
+	orders := make([]*ShardingOrder, 0, 8)
+	for i := int64(1); i <= 8; i++ {
+		orders = append(orders, &ShardingOrder{Id: i, UserId: i, Amount: i * 10})
+	}
+	res := NewInserter[ShardingOrder](sd).Values(orders...).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), affected)
+
+	// UserId % 2 决定库，UserId / 2 % 2 决定表
+	var cnt int
+	err = dbs["order_db_1"].db.QueryRow("SELECT COUNT(*) FROM order_tab_1 WHERE user_id IN (3, 7)").Scan(&cnt)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+	err = dbs["order_db_0"].db.QueryRow("SELECT COUNT(*) FROM order_tab_0 WHERE user_id IN (4, 8)").Scan(&cnt)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	testCases := []struct {
+		name    string
+		s       *Selector[ShardingOrder]
+		wantIds []int64
+		wantErr error
+	}{
+		{
+			name:    "eq",
+			s:       NewSelector[ShardingOrder](sd).Where(C("UserId").EQ(int64(3))),
+			wantIds: []int64{3},
+		},
+		{
+			name:    "in",
+			s:       NewSelector[ShardingOrder](sd).Where(C("UserId").In(int64(1), int64(2), int64(5))).OrderBy(Desc("Amount")),
+			wantIds: []int64{5, 2, 1},
+		},
+		{
+			name: "or",
+			s: NewSelector[ShardingOrder](sd).
+				Where(C("UserId").EQ(int64(1)).Or(C("UserId").EQ(int64(6)))).OrderBy(Asc("Id")),
+			wantIds: []int64{1, 6},
+		},
+		{
+			// 1 和 5 在同一个分片
+			name: "and",
+			s: NewSelector[ShardingOrder](sd).
+				Where(C("UserId").In(int64(1), int64(5)), C("Amount").GT(20)),
+			wantIds: []int64{5},
+		},
+		{
+			name:    "broadcast",
+			s:       NewSelector[ShardingOrder](sd).Where(C("Amount").GT(20)).OrderBy(Desc("Amount")).Limit(3).Offset(1),
+			wantIds: []int64{7, 6, 5},
+		},
+		{
+			name:    "broadcast offset",
+			s:       NewSelector[ShardingOrder](sd).OrderBy(Asc("Amount")).Limit(10).Offset(6),
+			wantIds: []int64{7, 8},
+		},
+		{
+			name:    "no rows",
+			s:       NewSelector[ShardingOrder](sd).Where(C("Amount").GT(100)),
+			wantErr: sql.ErrNoRows,
+		},
+		{
+			name: "having",
+			s: NewSelector[ShardingOrder](sd).Select(C("UserId"), Sum("Amount").As("amount")).
+				GroupBy(C("UserId")).Having(Sum("Amount").GT(10)),
+			wantErr: errs.NewErrShardingNotSupported("跨分片的 HAVING"),
+		},
+		{
+			name:    "distinct",
+			s:       NewSelector[ShardingOrder](sd).Select(C("Amount")).Distinct(),
+			wantErr: errs.NewErrShardingNotSupported("跨分片的 DISTINCT"),
+		},
+		{
+			// 只有一个分片的时候由数据库去重
+			name:    "distinct single shard",
+			s:       NewSelector[ShardingOrder](sd).Select(C("Id")).Where(C("UserId").EQ(int64(3))).Distinct(),
+			wantIds: []int64{3},
+		},
+		{
+			name:    "order by expression",
+			s:       NewSelector[ShardingOrder](sd).OrderBy(DescExpr(C("Amount").Add(1))),
+			wantErr: errs.NewErrShardingNotSupported("跨分片按照表达式排序"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.s.GetMulti(ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			ids := make([]int64, 0, len(got))
+			for _, o := range got {
+				ids = append(ids, o.Id)
+			}
+			assert.Equal(t, tc.wantIds, ids)
+		})
+	}
+
+	o, err := NewSelector[ShardingOrder](sd).Where(C("UserId").EQ(int64(4))).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), o.Amount)
+
+	res = NewUpdater[ShardingOrder](sd).Set(Assign("Amount", 1)).Where(C("UserId").In(int64(4), int64(5))).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	res = NewUpdater[ShardingOrder](sd).Set(Assign("Amount", 2)).Where(C("Amount").EQ(1)).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+}
+
+func TestShardingDB_Write(t *testing.T) {
+	sd, dbs := newShardingDB(t, "sharding_write")
+	ctx := context.Background()
+	wantErr := errs.NewErrCrossShardWrite(2)
+	// 默认不允许涉及多个分片的写操作
+	res := NewInserter[ShardingOrder](sd).Values(
+		&ShardingOrder{Id: 1, UserId: 1, Amount: 10},
+		&ShardingOrder{Id: 2, UserId: 2, Amount: 20},
+	).Exec(ctx)
+	assert.Equal(t, wantErr, res.Err())
+	res = NewUpdater[ShardingOrder](sd).Set(Assign("Amount", 1)).Where(C("UserId").In(int64(1), int64(2))).Exec(ctx)
+	assert.Equal(t, wantErr, res.Err())
+	res = NewDeleter[ShardingOrder](sd).Where(C("UserId").In(int64(1), int64(2))).Exec(ctx)
+	assert.Equal(t, wantErr, res.Err())
+	res = NewBulkUpdater[ShardingOrder](sd).Values(
+		&ShardingOrder{Id: 1, UserId: 1, Amount: 10},
+		&ShardingOrder{Id: 2, UserId: 2, Amount: 20},
+	).Exec(ctx)
+	assert.Equal(t, wantErr, res.Err())
+	res = NewDeleter[ShardingOrder](sd).Exec(ctx)
+	assert.Equal(t, errs.NewErrCrossShardWrite(4), res.Err())
+
+	// 1 和 5 在同一个分片
+	res = NewInserter[ShardingOrder](sd).Values(
+		&ShardingOrder{Id: 1, UserId: 1, Amount: 10},
+		&ShardingOrder{Id: 5, UserId: 5, Amount: 50},
+	).Exec(ctx)
+	require.NoError(t, res.Err())
+	res = NewBulkUpdater[ShardingOrder](sd).Values(
+		&ShardingOrder{Id: 1, UserId: 1, Amount: 11},
+		&ShardingOrder{Id: 5, UserId: 5, Amount: 51},
+	).Columns("Amount").Exec(ctx)
+	require.NoError(t, res.Err())
+	got, err := NewSelector[ShardingOrder](sd).Where(C("UserId").EQ(int64(5))).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(51), got.Amount)
+	res = NewDeleter[ShardingOrder](sd).Where(C("UserId").EQ(int64(1))).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	var cnt int
+	err = dbs["order_db_1"].db.QueryRow("SELECT COUNT(*) FROM order_tab_0").Scan(&cnt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+}
+
+func TestShardingDB_PartialWrite(t *testing.T) {
+	sd, dbs := newShardingDB(t, "sharding_partial", ShardingWithCrossShardWrite(true))
+	ctx := context.Background()
+	orders := make([]*ShardingOrder, 0, 4)
+	for i := int64(1); i <= 4; i++ {
+		orders = append(orders, &ShardingOrder{Id: i, UserId: i, Amount: i * 10})
+	}
+	res := NewInserter[ShardingOrder](sd).Values(orders...).Exec(ctx)
+	require.NoError(t, res.Err())
+	res = NewDeleter[ShardingOrder](sd).Where(C("Amount").GT(20)).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	// 最后一个分片写入失败，错误中列出已经写入的分片
+	_, err = dbs["order_db_1"].db.Exec("DROP TABLE order_tab_1")
+	require.NoError(t, err)
+	res = NewUpdater[ShardingOrder](sd).Set(Assign("Amount", 1)).Exec(ctx)
+	assert.ErrorContains(t, res.Err(), "morm 分片 order_db_0.order_tab_0,order_db_0.order_tab_1,order_db_1.order_tab_0 已经写入")
+	assert.ErrorContains(t, res.Err(), "no such table: order_tab_1")
+	var amount int64
+	err = dbs["order_db_0"].db.QueryRow("SELECT amount FROM order_tab_1 WHERE user_id = 2").Scan(&amount)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), amount)
+}
+
+func TestShardingDB_Reject(t *testing.T) {
+	sd, _ := newShardingDB(t, "sharding_reject", ShardingWithFanOut(FanOutReject))
+	ctx := context.Background()
+	_, err := NewSelector[ShardingOrder](sd).Where(C("Amount").GT(1)).GetMulti(ctx)
+	assert.Equal(t, errs.NewErrMissingShardingKey("UserId"), err)
+	res := NewUpdater[ShardingOrder](sd).Set(Assign("Amount", 1)).Exec(ctx)
+	assert.Equal(t, errs.NewErrMissingShardingKey("UserId"), res.Err())
+
+	// 没有分片规则
+	_, err = NewSelector[TestModel](sd).GetMulti(ctx)
+	assert.Equal(t, errs.NewErrMissingShardingRule("test_model"), err)
+	// 不能直接执行 SQL
+	_, err = RawQuery[ShardingOrder](sd, "SELECT 1").Get(ctx)
+	assert.Equal(t, errs.NewErrShardingNotSupported("直接执行 SQL"), err)
+}
+
+func TestShardingDB_route(t *testing.T) {
+	sd, _ := newShardingDB(t, "sharding_route")
+	m, err := sd.r.Get(&ShardingOrder{})
+	require.NoError(t, err)
+	dst := func(db, tab int) Dst {
+		return Dst{DB: fmt.Sprintf("order_db_%d", db), Table: fmt.Sprintf("order_tab_%d", tab)}
+	}
+	all := []Dst{dst(0, 0), dst(0, 1), dst(1, 0), dst(1, 1)}
+	testCases := []struct {
+		name    string
+		where   []Predicate
+		want    []Dst
+		wantErr error
+	}{
+		{
+			name: "no where",
+			want: all,
+		},
+		{
+			name:  "eq",
+			where: []Predicate{C("UserId").EQ(6)},
+			want:  []Dst{dst(0, 1)},
+		},
+		{
+			name:  "not sharding key",
+			where: []Predicate{C("Id").EQ(6)},
+			want:  all,
+		},
+		{
+			name:  "and empty",
+			where: []Predicate{C("UserId").EQ(1), C("UserId").EQ(2)},
+			want:  []Dst{},
+		},
+		{
+			name:  "or with other column",
+			where: []Predicate{C("UserId").EQ(1).Or(C("Amount").EQ(2))},
+			want:  all,
+		},
+		{
+			name:  "not",
+			where: []Predicate{Not(C("UserId").EQ(1))},
+			want:  all,
+		},
+		{
+			name:    "invalid value",
+			where:   []Predicate{C("UserId").EQ(1.5)},
+			wantErr: errs.NewErrInvalidShardingValue(1.5),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sd.route(m, tc.where)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestShardingAlgorithm(t *testing.T) {
+	rg := &RangeSharding{Ranges: []ShardRange{
+		{Start: 0, End: 100, Dst: Dst{DB: "db_0", Table: "tab_0"}},
+		{Start: 100, End: 200, Dst: Dst{DB: "db_0", Table: "tab_1"}},
+		{Start: 200, End: 300, Dst: Dst{DB: "db_0", Table: "tab_1"}},
+	}}
+	date := &DateSharding{
+		DBPattern:    "db_%s",
+		DBLayout:     "2006",
+		TablePattern: "tab_%s",
+		TableLayout:  "200601",
+		Start:        time.Date(2022, 11, 15, 0, 0, 0, 0, time.UTC),
+		End:          time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+		Step:         DateStepMonth,
+	}
+	testCases := []struct {
+		name      string
+		algorithm ShardingAlgorithm
+		val       any
+		want      Dst
+		wantErr   error
+	}{
+		{
+			name:      "hash string",
+			algorithm: &HashSharding{DBPattern: "db_%d", DBCount: 1, TablePattern: "tab_%d", TableCount: 1},
+			val:       "abc",
+			want:      Dst{DB: "db_0", Table: "tab_0"},
+		},
+		{
+			name:      "hash negative",
+			algorithm: &HashSharding{DBPattern: "db_%d", DBCount: 2, TablePattern: "tab_%d", TableCount: 4},
+			val:       -7,
+			want:      Dst{DB: "db_1", Table: "tab_3"},
+		},
+		{
+			name:      "range",
+			algorithm: rg,
+			val:       uint(150),
+			want:      Dst{DB: "db_0", Table: "tab_1"},
+		},
+		{
+			name:      "range out of bounds",
+			algorithm: rg,
+			val:       300,
+			wantErr:   errs.NewErrInvalidShardingValue(300),
+		},
+		{
+			name:      "date",
+			algorithm: date,
+			val:       time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC),
+			want:      Dst{DB: "db_2023", Table: "tab_202301"},
+		},
+		{
+			name:      "date invalid",
+			algorithm: date,
+			val:       "2023-01-31",
+			wantErr:   errs.NewErrInvalidShardingValue("2023-01-31"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.algorithm.Sharding(tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+	assert.Equal(t, []Dst{{DB: "db_0", Table: "tab_0"}, {DB: "db_0", Table: "tab_1"}}, rg.Broadcast())
+	assert.Equal(t, []Dst{
+		{DB: "db_2022", Table: "tab_202211"},
+		{DB: "db_2022", Table: "tab_202212"},
+		{DB: "db_2023", Table: "tab_202301"},
+		{DB: "db_2023", Table: "tab_202302"},
+	}, date.Broadcast())
+}
@@ -0,0 +1,209 @@
+package morm
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// DBWithStmtCache 缓存预编译语句，避免数据库反复解析相同的 SQL
+// size 是最多缓存的语句数量，超过之后关闭最久没有使用的语句
+// 只有主库会使用缓存，事务中会通过 sql.Tx.StmtContext 将缓存的语句绑定到事务上
+// size 小于等于 0 的时候不开启缓存
+func DBWithStmtCache(size int) DBOption {
+	return func(db *DB) error {
+		if size <= 0 {
+			db.stmts = nil
+			return nil
+		}
+		db.stmts = newStmtCache(size)
+		return nil
+	}
+}
+
+// StmtCacheStats 预编译语句缓存的统计数据
+type StmtCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Size 当前缓存的语句数量
+	Size int
+}
+
+// HitRate 命中率，没有请求的时候是 0
+func (s StmtCacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// StmtCacheStats 返回预编译语句缓存的统计数据，没有开启缓存的时候都是 0
+func (db *DB) StmtCacheStats() StmtCacheStats {
+	if db.stmts == nil {
+		return StmtCacheStats{}
+	}
+	return db.stmts.stats()
+}
+
+type stmtEntry struct {
+	query string
+	stmt  *sql.Stmt
+	// refs 正在使用这个语句的数量，被淘汰的语句要等到没有人使用才关闭
+	refs    int
+	evicted bool
+}
+
+// stmtCache 按照 SQL 缓存 *sql.Stmt 的 LRU 缓存
+type stmtCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// lru 最近使用的在前面
+	lru *list.List
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		lru:     list.New(),
+	}
+}
+
+// acquire 返回 query 对应的预编译语句，用完之后必须调用 release
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) (*stmtEntry, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[query]; ok {
+		c.hits++
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*stmtEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// 预编译需要和数据库交互，所以不持有锁
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[query]; ok {
+		// 其它 goroutine 已经预编译了同样的语句
+		_ = stmt.Close()
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.entries[query] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.evict(c.lru.Back())
+	}
+	return entry, nil
+}
+
+// release 释放语句，已经被淘汰并且没有人使用的语句会被关闭
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// evict 需要持有锁
+func (c *stmtCache) evict(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*stmtEntry)
+	delete(c.entries, entry.query)
+	c.evictions++
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) stats() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return StmtCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.lru.Len(),
+	}
+}
+
+// close 关闭所有缓存的语句
+func (c *stmtCache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for _, elem := range c.entries {
+		entry := elem.Value.(*stmtEntry)
+		if e := entry.stmt.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	c.entries = make(map[string]*list.Element, c.size)
+	c.lru.Init()
+	return err
+}
+
+// queryContext 使用缓存的语句查询
+// 返回的 *sql.Rows 依赖于语句，database/sql 会等到 Rows 关闭之后才真正关闭语句，所以这里可以立刻释放
+func (c *stmtCache) queryContext(ctx context.Context, db *sql.DB, query string, args ...any) (*sql.Rows, error) {
+	entry, err := c.acquire(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(entry)
+	return entry.stmt.QueryContext(ctx, args...)
+}
+
+func (c *stmtCache) execContext(ctx context.Context, db *sql.DB, query string, args ...any) (sql.Result, error) {
+	entry, err := c.acquire(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(entry)
+	return entry.stmt.ExecContext(ctx, args...)
+}
+
+// txStmt 将缓存的语句绑定到事务上，同一个事务中只绑定一次
+// 事务结束的时候 database/sql 会自动关闭绑定的语句
+func (t *Tx) txStmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	t.mu.Lock()
+	stmt, ok := t.stmts[query]
+	t.mu.Unlock()
+	if ok {
+		return stmt, nil
+	}
+	c := t.db.stmts
+	entry, err := c.acquire(ctx, t.db.db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(entry)
+	stmt = t.tx.StmtContext(ctx, entry.stmt)
+	t.mu.Lock()
+	if t.stmts == nil {
+		t.stmts = make(map[string]*sql.Stmt, 4)
+	}
+	t.stmts[query] = stmt
+	t.mu.Unlock()
+	return stmt, nil
+}
@@ -0,0 +1,145 @@
+package morm
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestDB_StmtCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithStmtCache(1))
+	require.NoError(t, err)
+	ctx := context.Background()
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id"}).AddRow(1)
+	}
+
+	// 第一次预编译，第二次命中缓存
+	byId := mock.ExpectPrepare("SELECT \\* FROM `test_model` WHERE `id` = \\?;")
+	byId.ExpectQuery().WithArgs(1).WillReturnRows(rows())
+	byId.ExpectQuery().WithArgs(2).WillReturnRows(rows())
+	// 缓存只有一个位置，预编译新的语句会关闭旧的语句
+	byId.WillBeClosed()
+	mock.ExpectPrepare("UPDATE `test_model` SET `age`=\\?;").
+		ExpectExec().WithArgs(18).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// GetMulti 会读完结果集，连接可以复用，所以不会在另外一个连接上重新预编译
+	_, err = NewSelector[TestModel](db).Where(C("Id").EQ(1)).GetMulti(ctx)
+	require.NoError(t, err)
+	_, err = NewSelector[TestModel](db).Where(C("Id").EQ(2)).GetMulti(ctx)
+	require.NoError(t, err)
+	res := NewUpdater[TestModel](db).Set(Assign("Age", 18)).Exec(ctx)
+	require.NoError(t, res.Err())
+
+	stats := db.StmtCacheStats()
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 1}, stats)
+	assert.Equal(t, 1.0/3, stats.HitRate())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_StmtCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithStmtCache(8))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 在事务中只绑定一次，保存点不经过缓存
+	// 事务占用了连接，所以语句先在另外一个连接上预编译，绑定到事务的时候再在事务的连接上预编译
+	mock.ExpectBegin()
+	mock.ExpectPrepare("UPDATE `test_model` SET `age`=\\?;")
+	prep := mock.ExpectPrepare("UPDATE `test_model` SET `age`=\\?;")
+	prep.ExpectExec().WithArgs(18).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs(19).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		if err := NewUpdater[TestModel](tx).Set(Assign("Age", 18)).Exec(ctx).Err(); err != nil {
+			return err
+		}
+		return tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+			return NewUpdater[TestModel](tx).Set(Assign("Age", 19)).Exec(ctx).Err()
+		}, nil)
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StmtCacheStats{Misses: 1, Size: 1}, db.StmtCacheStats())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDB_StmtCache_Concurrent(t *testing.T) {
+	db := memoryDBWithDB("stmt_cache_concurrent", t, DBWithDialect(SQLite3), DBWithStmtCache(2))
+	_, err := db.db.Exec(shardingOrderCreateSQL("sharding_order"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	for i := int64(1); i <= 4; i++ {
+		res := NewInserter[ShardingOrder](db).Values(&ShardingOrder{Id: i, UserId: i, Amount: i * 10}).Exec(ctx)
+		require.NoError(t, res.Err())
+	}
+
+	// 缓存的位置比语句少，会不断地淘汰正在使用的语句
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				var s *Selector[ShardingOrder]
+				switch (i + j) % 3 {
+				case 0:
+					s = NewSelector[ShardingOrder](db).Where(C("Id").EQ(int64(1)))
+				case 1:
+					s = NewSelector[ShardingOrder](db).Where(C("UserId").EQ(int64(1)))
+				default:
+					s = NewSelector[ShardingOrder](db).Where(C("Amount").EQ(int64(10)))
+				}
+				o, err := s.Get(ctx)
+				if assert.NoError(t, err) {
+					assert.Equal(t, int64(1), o.Id)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := db.StmtCacheStats()
+	assert.Equal(t, uint64(4+8*50), stats.Hits+stats.Misses)
+	assert.Equal(t, 2, stats.Size)
+	assert.NoError(t, db.Close())
+	_, err = NewSelector[ShardingOrder](db).Get(ctx)
+	assert.Equal(t, "sql: database is closed", err.Error())
+}
+
+func TestDB_StmtCache_Disabled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithStmtCache(0))
+	require.NoError(t, err)
+	assert.Nil(t, db.stmts)
+
+	// 不预编译，直接执行
+	mock.ExpectQuery("SELECT \\* FROM `test_model` WHERE `id` = \\?;").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, StmtCacheStats{}, db.StmtCacheStats())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	db, err = OpenDB(mockDB, DBWithStmtCache(-1))
+	require.NoError(t, err)
+	assert.Nil(t, db.stmts)
+}
+
+func TestStmtCacheStats_HitRate(t *testing.T) {
+	assert.Equal(t, 0.0, StmtCacheStats{}.HitRate())
+	assert.Equal(t, 0.75, StmtCacheStats{Hits: 3, Misses: 1}.HitRate())
+}
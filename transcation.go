@@ -16,6 +16,8 @@ package morm
 import (
 	"context"
 	"database/sql"
+	"github.com/NotFound1911/morm/errors"
+	"sync"
 )
 
 var _ session = &Tx{}
@@ -31,9 +33,20 @@ type session interface {
 type Tx struct {
 	tx *sql.Tx
 	db *DB
-	// 事务扩散方案里面，
-	// 这个要在 commit 或者 rollback 的时候修改为 true
-	// done bool
+	// ctx 开启事务时候的 context，用于执行回调
+	ctx context.Context
+	// savepoints 已经创建的保存点数量，用于生成 DoTx 的保存点名字
+	savepoints int
+
+	mu sync.Mutex
+	// done 在 commit 或者 rollback 的时候修改为 true
+	done      bool
+	committed bool
+	// afterCommit 和 afterRollback 按照注册的顺序执行
+	afterCommit   []func(ctx context.Context)
+	afterRollback []func(ctx context.Context)
+	// stmts 绑定到这个事务上的预编译语句，只有开启了 DBWithStmtCache 才会使用
+	stmts map[string]*sql.Stmt
 }
 
 func (t *Tx) getCore() core {
@@ -41,25 +54,136 @@ func (t *Tx) getCore() core {
 }
 
 func (t *Tx) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if t.db.stmts != nil {
+		stmt, err := t.txStmt(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return stmt.QueryContext(ctx, args...)
+	}
 	return t.tx.QueryContext(ctx, query, args...)
 }
 
 func (t *Tx) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if t.db.stmts != nil {
+		stmt, err := t.txStmt(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return stmt.ExecContext(ctx, args...)
+	}
 	return t.tx.ExecContext(ctx, query, args...)
 }
 
+// AfterCommit 注册事务提交成功之后执行的回调，例如发布事件、删除缓存
+// 如果事务已经提交，那么立刻执行；如果事务已经回滚，那么不会执行
+func (t *Tx) AfterCommit(fn func(ctx context.Context)) {
+	t.mu.Lock()
+	if !t.done {
+		t.afterCommit = append(t.afterCommit, fn)
+		t.mu.Unlock()
+		return
+	}
+	committed := t.committed
+	t.mu.Unlock()
+	if committed {
+		t.runCallbacks([]func(ctx context.Context){fn})
+	}
+}
+
+// AfterRollback 注册事务回滚之后执行的回调，提交失败也认为是回滚
+// 如果事务已经回滚，那么立刻执行；如果事务已经提交，那么不会执行
+func (t *Tx) AfterRollback(fn func(ctx context.Context)) {
+	t.mu.Lock()
+	if !t.done {
+		t.afterRollback = append(t.afterRollback, fn)
+		t.mu.Unlock()
+		return
+	}
+	committed := t.committed
+	t.mu.Unlock()
+	if !committed {
+		t.runCallbacks([]func(ctx context.Context){fn})
+	}
+}
+
 func (t *Tx) Commit() error {
-	return t.tx.Commit()
+	err := t.tx.Commit()
+	t.finish(err == nil)
+	return err
 }
 
 func (t *Tx) Rollback() error {
-	return t.tx.Rollback()
+	err := t.tx.Rollback()
+	t.finish(false)
+	return err
 }
 
 func (t *Tx) RollbackIfNotCommit() error {
-	err := t.tx.Rollback()
+	err := t.Rollback()
 	if err != sql.ErrTxDone {
 		return err
 	}
 	return nil
 }
+
+// finish 第一次提交或者回滚的时候，按照结果执行对应的回调
+func (t *Tx) finish(committed bool) {
+	t.mu.Lock()
+	if t.done {
+		t.mu.Unlock()
+		return
+	}
+	t.done = true
+	t.committed = committed
+	fns := t.afterRollback
+	if committed {
+		fns = t.afterCommit
+	}
+	t.afterCommit, t.afterRollback = nil, nil
+	t.mu.Unlock()
+	t.runCallbacks(fns)
+}
+
+// runCallbacks 依次执行回调，一个回调 panic 不会影响其它回调，也不会影响事务的结果
+func (t *Tx) runCallbacks(fns []func(ctx context.Context)) {
+	for _, fn := range fns {
+		t.runCallback(fn)
+	}
+}
+
+func (t *Tx) runCallback(fn func(ctx context.Context)) {
+	defer func() {
+		if e := recover(); e != nil {
+			t.db.txCallbackErr(t.ctx, errs.NewErrTxCallbackPanic(e))
+		}
+	}()
+	fn(t.ctx)
+}
+
+// callbackMark 记录当前已经注册的回调数量，用于回滚到保存点
+type callbackMark struct {
+	commit   int
+	rollback int
+}
+
+func (t *Tx) markCallbacks() callbackMark {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return callbackMark{commit: len(t.afterCommit), rollback: len(t.afterRollback)}
+}
+
+// rollbackCallbacks 回滚到保存点之后，保存点之后注册的 AfterCommit 不应该再执行，
+// 而保存点之后注册的 AfterRollback 立刻执行
+func (t *Tx) rollbackCallbacks(m callbackMark) {
+	t.mu.Lock()
+	if t.done || len(t.afterCommit) < m.commit || len(t.afterRollback) < m.rollback {
+		t.mu.Unlock()
+		return
+	}
+	fns := append([]func(ctx context.Context){}, t.afterRollback[m.rollback:]...)
+	t.afterCommit = t.afterCommit[:m.commit]
+	t.afterRollback = t.afterRollback[:m.rollback]
+	t.mu.Unlock()
+	t.runCallbacks(fns)
+}
@@ -17,8 +17,11 @@ package morm
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/NotFound1911/morm/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -69,3 +72,153 @@ func TestTx_Rollback(t *testing.T) {
 	err = tx.Rollback()
 	assert.Nil(t, err)
 }
+
+func TestTx_Callbacks(t *testing.T) {
+	mockErr := errors.New("mock error")
+	testCases := []struct {
+		name      string
+		mock      func(mock sqlmock.Sqlmock)
+		fn        func(ctx context.Context, tx *Tx, record func(s string)) error
+		wantErr   error
+		wantCalls []string
+		wantPanic []error
+	}{
+		{
+			name: "commit",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string)) error {
+				tx.AfterCommit(func(ctx context.Context) { record("commit 1") })
+				tx.AfterRollback(func(ctx context.Context) { record("rollback") })
+				tx.AfterCommit(func(ctx context.Context) { record("commit 2") })
+				return nil
+			},
+			wantCalls: []string{"commit 1", "commit 2"},
+		},
+		{
+			name: "rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string)) error {
+				tx.AfterCommit(func(ctx context.Context) { record("commit") })
+				tx.AfterRollback(func(ctx context.Context) { record("rollback") })
+				return mockErr
+			},
+			wantErr:   mockErr,
+			wantCalls: []string{"rollback"},
+		},
+		{
+			name: "commit failed",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit().WillReturnError(mockErr)
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string)) error {
+				tx.AfterCommit(func(ctx context.Context) { record("commit") })
+				tx.AfterRollback(func(ctx context.Context) { record("rollback") })
+				return nil
+			},
+			wantErr:   errs.NewErrTxCommitFailed(mockErr),
+			wantCalls: []string{"rollback"},
+		},
+		{
+			name: "panic isolated",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string)) error {
+				tx.AfterCommit(func(ctx context.Context) { panic("mock panic") })
+				tx.AfterCommit(func(ctx context.Context) { record("commit") })
+				return nil
+			},
+			wantCalls: []string{"commit"},
+			wantPanic: []error{errs.NewErrTxCallbackPanic("mock panic")},
+		},
+		{
+			name: "savepoint rollback",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string)) error {
+				tx.AfterCommit(func(ctx context.Context) { record("outer commit") })
+				_ = tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+					tx.AfterCommit(func(ctx context.Context) { record("inner commit") })
+					tx.AfterRollback(func(ctx context.Context) { record("inner rollback") })
+					return mockErr
+				}, nil)
+				return nil
+			},
+			wantCalls: []string{"inner rollback", "outer commit"},
+		},
+		{
+			name: "savepoint release",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("RELEASE SAVEPOINT `morm_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			fn: func(ctx context.Context, tx *Tx, record func(s string)) error {
+				return tx.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+					tx.AfterCommit(func(ctx context.Context) { record("inner commit") })
+					return nil
+				}, nil)
+			},
+			wantCalls: []string{"inner commit"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			var panics []error
+			db, err := OpenDB(mockDB, DBWithTxCallbackErrorHandler(func(ctx context.Context, err error) {
+				panics = append(panics, err)
+			}))
+			require.NoError(t, err)
+			tc.mock(mock)
+
+			var calls []string
+			err = db.DoTx(context.Background(), func(ctx context.Context, tx *Tx) error {
+				return tc.fn(ctx, tx, func(s string) {
+					calls = append(calls, s)
+				})
+			}, nil)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCalls, calls)
+			assert.Equal(t, tc.wantPanic, panics)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTx_CallbacksAfterDone(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	// 只有第一次提交或者回滚会触发回调
+	assert.Equal(t, sql.ErrTxDone, tx.Rollback())
+
+	var calls []string
+	tx.AfterCommit(func(ctx context.Context) { calls = append(calls, "commit") })
+	tx.AfterRollback(func(ctx context.Context) { calls = append(calls, "rollback") })
+	assert.Equal(t, []string{"commit"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -10,6 +10,7 @@ type Updater[T any] struct {
 	builder
 	val     *T
 	assigns []Assignable
+	ctes    []CTE
 	sess    session
 }
 
@@ -30,12 +31,19 @@ func (u *Updater[T]) Update(t *T) *Updater[T] {
 	return u
 }
 
+// With 在更新语句前面加上 WITH 子句，CTE 可以在 WHERE 的子查询中使用
+func (u *Updater[T]) With(ctes ...CTE) *Updater[T] {
+	u.ctes = ctes
+	return u
+}
+
 func (u *Updater[T]) Set(assigns ...Assignable) *Updater[T] {
 	u.assigns = assigns
 	return u
 }
 
 func (u *Updater[T]) Build() (*Query, error) {
+	u.reset()
 	var (
 		t   T
 		err error
@@ -47,6 +55,9 @@ func (u *Updater[T]) Build() (*Query, error) {
 	if len(u.assigns) == 0 {
 		return nil, errs.NewErrNoUpdatedColumns()
 	}
+	if err = u.buildWith(u.ctes); err != nil {
+		return nil, err
+	}
 	u.sqlBuilder.WriteString("UPDATE ")
 	if err = u.buildTable(u.table); err != nil {
 		return nil, err
@@ -92,13 +103,13 @@ func (u *Updater[T]) Build() (*Query, error) {
 func (u *Updater[T]) buildTable(table TableReference) error {
 	switch tab := table.(type) {
 	case nil:
-		u.quote(u.model.TableName)
+		u.quote(u.tableName(u.model))
 	case Table:
 		model, err := u.r.Get(tab.entity)
 		if err != nil {
 			return err
 		}
-		u.quote(model.TableName)
+		u.quote(u.tableName(model))
 		if tab.alias != "" {
 			u.sqlBuilder.WriteString(" AS ")
 			u.quote(tab.alias)
@@ -121,6 +132,9 @@ func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
 }
 
 func (u *Updater[T]) Exec(ctx context.Context) Result {
+	if sd, ok := u.sess.(*ShardingDB); ok {
+		return shardingUpdate[T](ctx, sd, u)
+	}
 	return exec(ctx, u.sess, u.core, &QueryContext{Builder: u, Type: "UPDATE"})
 }
 
@@ -135,21 +149,31 @@ func AssignNotNilColumns(entity interface{}) []Assignable {
 }
 
 func AssignNotZeroColumns(entity interface{}) []Assignable {
-	return AssignColumns(entity, func(typ reflect.StructField, val reflect.Value) bool {
-		return !val.IsZero()
-	})
+	return AssignColumns(entity, notZero)
+}
+
+func notZero(typ reflect.StructField, val reflect.Value) bool {
+	return !val.IsZero()
 }
 func AssignColumns(entity interface{}, filter func(typ reflect.StructField, val reflect.Value) bool) []Assignable {
+	res := make([]Assignable, 0, reflect.TypeOf(entity).Elem().NumField())
+	filterColumns(entity, filter, func(name string, val any) {
+		res = append(res, Assign(name, val))
+	})
+	return res
+}
+
+// filterColumns 遍历 entity 的字段，对满足 filter 的字段调用 fn
+func filterColumns(entity interface{}, filter func(typ reflect.StructField, val reflect.Value) bool,
+	fn func(name string, val any)) {
 	val := reflect.ValueOf(entity).Elem()
 	typ := reflect.TypeOf(entity).Elem()
 	numField := val.NumField()
-	res := make([]Assignable, 0, numField)
 	for i := 0; i < numField; i++ {
 		fieldVal := val.Field(i)
 		fieldTyp := typ.Field(i)
 		if filter(fieldTyp, fieldVal) {
-			res = append(res, Assign(fieldTyp.Name, fieldVal.Interface()))
+			fn(fieldTyp.Name, fieldVal.Interface())
 		}
 	}
-	return res
 }
@@ -0,0 +1,57 @@
+package morm
+
+import (
+	"reflect"
+	"sort"
+)
+
+// WhereExample 按照 entity 中的非零值字段构造查询条件，多个条件之间是 AND 的关系
+// 例如 NewSelector[User](db).Where(WhereExample(&User{FirstName: "Tom"})...)
+func WhereExample(entity interface{}) []Predicate {
+	res := make([]Predicate, 0, reflect.TypeOf(entity).Elem().NumField())
+	filterColumns(entity, notZero, func(name string, val any) {
+		res = append(res, C(name).EQ(val))
+	})
+	return res
+}
+
+// WhereMap 按照 map 构造查询条件，key 是字段名，多个条件之间是 AND 的关系
+// 值为 nil 的时候构造 IS NULL，值为切片的时候构造 IN，否则构造 =
+// key 不在模型中的时候，构造 SQL 会返回错误
+func WhereMap(m map[string]any) []Predicate {
+	// 按照 key 排序，保证生成的 SQL 是稳定的
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]Predicate, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, mapPredicate(C(k), m[k]))
+	}
+	return res
+}
+
+func mapPredicate(c Column, val any) Predicate {
+	if val == nil {
+		return c.IsNull()
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return c.IsNull()
+		}
+	case reflect.Slice, reflect.Array:
+		// []byte 是一个值，而不是值的列表
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		vals := make([]any, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			vals = append(vals, rv.Index(i).Interface())
+		}
+		return c.In(vals...)
+	}
+	return c.EQ(val)
+}
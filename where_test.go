@@ -0,0 +1,203 @@
+package morm
+
+import (
+	"database/sql"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWhereExample(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "one field",
+			q:    NewSelector[TestModel](db).Where(WhereExample(&TestModel{FirstName: "Tom"})...),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ?;",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "multiple fields",
+			q: NewSelector[TestModel](db).
+				Where(WhereExample(&TestModel{Id: 12, FirstName: "Tom", Age: 18})...),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`id` = ?) AND (`first_name` = ?)) AND (`age` = ?);",
+				Args: []any{int64(12), "Tom", int8(18)},
+			},
+		},
+		{
+			name: "pointer field",
+			q: NewSelector[TestModel](db).
+				Where(WhereExample(&TestModel{LastName: &sql.NullString{String: "Jerry", Valid: true}})...),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `last_name` = ?;",
+				Args: []any{&sql.NullString{String: "Jerry", Valid: true}},
+			},
+		},
+		{
+			name: "zero example",
+			q:    NewSelector[TestModel](db).Where(WhereExample(&TestModel{})...),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			name: "delete",
+			q:    NewDeleter[TestModel](db).Where(WhereExample(&TestModel{Age: 18})...),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `age` = ?;",
+				Args: []any{int8(18)},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestWhereMap(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "eq",
+			q:    NewSelector[TestModel](db).Where(WhereMap(map[string]any{"FirstName": "Tom"})...),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ?;",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			// 按照 key 排序
+			name: "sorted",
+			q: NewSelector[TestModel](db).Where(WhereMap(map[string]any{
+				"FirstName": "Tom",
+				"Age":       18,
+				"Id":        []int64{1, 2},
+				"LastName":  nil,
+			})...),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (((`age` = ?) AND (`first_name` = ?)) AND (`id` IN (?,?))) AND (`last_name` IS NULL);",
+				Args: []any{18, "Tom", int64(1), int64(2)},
+			},
+		},
+		{
+			name: "in",
+			q:    NewSelector[TestModel](db).Where(WhereMap(map[string]any{"Id": []int{1, 2, 3}})...),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?,?);",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			name: "nil pointer",
+			q:    NewSelector[TestModel](db).Where(WhereMap(map[string]any{"LastName": (*sql.NullString)(nil)})...),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `last_name` IS NULL;",
+			},
+		},
+		{
+			name: "bytes",
+			q:    NewSelector[TestModel](db).Where(WhereMap(map[string]any{"FirstName": []byte("Tom")})...),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ?;",
+				Args: []any{[]byte("Tom")},
+			},
+		},
+		{
+			name:    "empty in",
+			q:       NewSelector[TestModel](db).Where(WhereMap(map[string]any{"Id": []int{}})...),
+			wantErr: errs.NewErrEmptyInValues("Id"),
+		},
+		{
+			name:    "unknown field",
+			q:       NewSelector[TestModel](db).Where(WhereMap(map[string]any{"Invalid": 1})...),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestColumn_Predicate(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "in",
+			q:    NewSelector[TestModel](db).Where(C("Id").In(1, 2)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?);",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "not in",
+			q:    NewSelector[TestModel](db).Where(Not(C("Id").In(1, 2))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE  NOT (`id` IN (?,?));",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "neq lteq gteq",
+			q:    NewSelector[TestModel](db).Where(C("Id").NEQ(1), C("Age").LTEQ(60), C("Age").GTEQ(18)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`id` != ?) AND (`age` <= ?)) AND (`age` >= ?);",
+				Args: []any{1, 60, 18},
+			},
+		},
+		{
+			name: "is not null",
+			q:    NewSelector[TestModel](db).Where(C("LastName").IsNotNull()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `last_name` IS NOT NULL;",
+			},
+		},
+		{
+			name:    "empty",
+			q:       NewSelector[TestModel](db).Where(C("Id").In()),
+			wantErr: errs.NewErrEmptyInValues("Id"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
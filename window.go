@@ -0,0 +1,194 @@
+package morm
+
+// Window 窗口定义，对应 OVER (PARTITION BY ... ORDER BY ... frame)
+type Window struct {
+	PartitionBy []Column
+	OrderBy     []OrderBy
+	// Frame 窗口帧，原样输出，例如 ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+	Frame string
+}
+
+var _ Selectable = WindowFunc{}
+var _ Expression = WindowFunc{}
+
+// WindowFunc 窗口函数：ROW_NUMBER, RANK, LAG, SUM(...) OVER ...
+type WindowFunc struct {
+	fn   string
+	args []Expression
+	// agg 不为空的时候代表聚合函数作为窗口函数使用
+	agg    *Aggregate
+	window Window
+	// windowName 不为空的时候代表引用 WINDOW 子句中定义的窗口
+	windowName string
+	alias      string
+}
+
+func (w WindowFunc) fieldName() string {
+	return ""
+}
+
+func (w WindowFunc) target() TableReference {
+	return nil
+}
+
+func (w WindowFunc) selectedAlias() string {
+	return w.alias
+}
+
+func (WindowFunc) expr() {}
+
+// Over 设置窗口
+func (w WindowFunc) Over(win Window) WindowFunc {
+	w.window = win
+	return w
+}
+
+// OverWindow 使用 Selector.Window 定义的窗口，例如 OVER `w`
+func (w WindowFunc) OverWindow(name string) WindowFunc {
+	w.windowName = name
+	return w
+}
+
+func (w WindowFunc) As(alias string) WindowFunc {
+	w.alias = alias
+	return w
+}
+
+// OverWindow 将聚合函数作为窗口函数使用，窗口由 Selector.Window 定义
+func (a Aggregate) OverWindow(name string) WindowFunc {
+	return a.Over(Window{}).OverWindow(name)
+}
+
+// Over 将聚合函数作为窗口函数使用，例如 SUM(`amount`) OVER (ORDER BY `id`)
+func (a Aggregate) Over(win Window) WindowFunc {
+	alias := a.alias
+	a.alias = ""
+	return WindowFunc{
+		agg:    &a,
+		window: win,
+		alias:  alias,
+	}
+}
+
+func RowNumber() WindowFunc {
+	return WindowFunc{fn: "ROW_NUMBER"}
+}
+
+func Rank() WindowFunc {
+	return WindowFunc{fn: "RANK"}
+}
+
+func DenseRank() WindowFunc {
+	return WindowFunc{fn: "DENSE_RANK"}
+}
+
+// Lag 取当前行之前第 offset 行的值
+func Lag(col string, offset int) WindowFunc {
+	return WindowFunc{
+		fn:   "LAG",
+		args: []Expression{C(col), valueOf(offset)},
+	}
+}
+
+// Lead 取当前行之后第 offset 行的值
+func Lead(col string, offset int) WindowFunc {
+	return WindowFunc{
+		fn:   "LEAD",
+		args: []Expression{C(col), valueOf(offset)},
+	}
+}
+
+// 构建窗口函数
+func (b *builder) buildWindowFunc(w WindowFunc, useAlias bool) error {
+	if w.agg != nil {
+		if err := b.buildAggregate(*w.agg, false); err != nil {
+			return err
+		}
+	} else {
+		b.sqlBuilder.WriteString(w.fn)
+		b.sqlBuilder.WriteByte('(')
+		for i, arg := range w.args {
+			if i > 0 {
+				b.sqlBuilder.WriteByte(',')
+			}
+			if err := b.buildExpression(arg); err != nil {
+				return err
+			}
+		}
+		b.sqlBuilder.WriteByte(')')
+	}
+	b.sqlBuilder.WriteString(" OVER ")
+	if w.windowName != "" {
+		b.quote(w.windowName)
+	} else {
+		b.sqlBuilder.WriteByte('(')
+		if err := b.buildWindow(w.window); err != nil {
+			return err
+		}
+		b.sqlBuilder.WriteByte(')')
+	}
+	if useAlias {
+		b.buildAs(w.alias)
+	}
+	return nil
+}
+
+func (b *builder) buildWindow(win Window) error {
+	sep := ""
+	if len(win.PartitionBy) > 0 {
+		b.sqlBuilder.WriteString("PARTITION BY ")
+		for i, col := range win.PartitionBy {
+			if i > 0 {
+				b.sqlBuilder.WriteByte(',')
+			}
+			if err := b.buildColumn(col.table, col.name); err != nil {
+				return err
+			}
+		}
+		sep = " "
+	}
+	if len(win.OrderBy) > 0 {
+		b.sqlBuilder.WriteString(sep)
+		b.sqlBuilder.WriteString("ORDER BY ")
+		if err := b.buildOrderBys(win.OrderBy); err != nil {
+			return err
+		}
+		sep = " "
+	}
+	if win.Frame != "" {
+		b.sqlBuilder.WriteString(sep)
+		b.sqlBuilder.WriteString(win.Frame)
+	}
+	return nil
+}
+
+type namedWindow struct {
+	name   string
+	window Window
+}
+
+// Window 定义一个命名窗口，对应 WINDOW `name` AS (...)，
+// 多个窗口函数可以通过 OverWindow 共用同一个窗口
+func (s *Selector[T]) Window(name string, win Window) *Selector[T] {
+	s.windows = append(s.windows, namedWindow{name: name, window: win})
+	return s
+}
+
+func (s *Selector[T]) buildWindows() error {
+	if len(s.windows) == 0 {
+		return nil
+	}
+	s.sqlBuilder.WriteString(" WINDOW ")
+	for i, w := range s.windows {
+		if i > 0 {
+			s.sqlBuilder.WriteByte(',')
+		}
+		s.quote(w.name)
+		s.sqlBuilder.WriteString(" AS (")
+		if err := s.buildWindow(w.window); err != nil {
+			return err
+		}
+		s.sqlBuilder.WriteByte(')')
+	}
+	return nil
+}
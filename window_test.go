@@ -0,0 +1,124 @@
+package morm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/NotFound1911/morm/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWindowFunc_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "row number",
+			q: NewSelector[TestModel](db).Select(C("Id"),
+				RowNumber().Over(Window{
+					PartitionBy: []Column{C("LastName")},
+					OrderBy:     []OrderBy{Desc("Age")},
+				}).As("rn")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,ROW_NUMBER() OVER (PARTITION BY `last_name` ORDER BY `age` DESC) AS `rn` FROM `test_model`;",
+			},
+		},
+		{
+			name: "empty window",
+			q:    NewSelector[TestModel](db).Select(Rank().Over(Window{}), DenseRank().Over(Window{})),
+			wantQuery: &Query{
+				SQL: "SELECT RANK() OVER (),DENSE_RANK() OVER () FROM `test_model`;",
+			},
+		},
+		{
+			name: "lag and lead",
+			q: NewSelector[TestModel](db).Select(
+				Lag("Age", 1).Over(Window{OrderBy: []OrderBy{Asc("Id")}}).As("prev_age"),
+				Lead("Age", 2).Over(Window{OrderBy: []OrderBy{Asc("Id")}}).As("next_age")),
+			wantQuery: &Query{
+				SQL: "SELECT LAG(`age`,?) OVER (ORDER BY `id` ASC) AS `prev_age`," +
+					"LEAD(`age`,?) OVER (ORDER BY `id` ASC) AS `next_age` FROM `test_model`;",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "aggregate with frame",
+			q: NewSelector[TestModel](db).Select(
+				Sum("Age").As("total").Over(Window{
+					OrderBy: []OrderBy{Asc("Id")},
+					Frame:   "ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW",
+				})),
+			wantQuery: &Query{
+				SQL: "SELECT SUM(`age`) OVER (ORDER BY `id` ASC ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS `total` FROM `test_model`;",
+			},
+		},
+		{
+			name: "invalid partition",
+			q: NewSelector[TestModel](db).Select(
+				RowNumber().Over(Window{PartitionBy: []Column{C("Invalid")}})),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "invalid order by",
+			q: NewSelector[TestModel](db).Select(
+				RowNumber().Over(Window{OrderBy: []OrderBy{Asc("Invalid")}})),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id"),
+					RowNumber().Over(Window{
+						PartitionBy: []Column{C("LastName")},
+						OrderBy:     []OrderBy{Desc("Age")},
+					}).As("rn")).AsSubquery("t")
+				return NewSelector[TestModel](db).Select(sub.C("Id")).From(sub).Where(sub.C("rn").EQ(1))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `t`.`id` FROM (SELECT `id`,ROW_NUMBER() OVER (PARTITION BY `last_name` ORDER BY `age` DESC) AS `rn` FROM `test_model`) AS `t` " +
+					"WHERE `t`.`rn` = ?;",
+				Args: []any{1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestWindowFunc_GetMulti(t *testing.T) {
+	db := memoryDBWithDB("window_func", t, DBWithDialect(SQLite3))
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	res := NewInserter[TestModel](db).Values(
+		&TestModel{Id: 1, FirstName: "a", Age: 10, LastName: &sql.NullString{String: "A", Valid: true}},
+		&TestModel{Id: 2, FirstName: "b", Age: 20, LastName: &sql.NullString{String: "A", Valid: true}},
+		&TestModel{Id: 3, FirstName: "c", Age: 30, LastName: &sql.NullString{String: "B", Valid: true}},
+		&TestModel{Id: 4, FirstName: "d", Age: 5, LastName: &sql.NullString{String: "B", Valid: true}},
+	).Exec(context.Background())
+	require.NoError(t, res.Err())
+
+	// 每个 LastName 中年龄最大的
+	sub := NewSelector[TestModel](db).Select(C("Id"),
+		RowNumber().Over(Window{
+			PartitionBy: []Column{C("LastName")},
+			OrderBy:     []OrderBy{Desc("Age")},
+		}).As("rn")).AsSubquery("t")
+	got, err := NewSelector[TestModel](db).Select(sub.C("Id")).From(sub).
+		Where(sub.C("rn").EQ(1)).OrderBy(Asc("Id")).GetMulti(context.Background())
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, []int64{2, 3}, []int64{got[0].Id, got[1].Id})
+}